@@ -0,0 +1,77 @@
+package hashed
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"lukechampine.com/blake3"
+)
+
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	SHA384 Algorithm = "sha384"
+	SHA512 Algorithm = "sha512"
+	BLAKE2b Algorithm = "blake2b"
+	BLAKE3 Algorithm = "blake3"
+	MD5 Algorithm = "md5"
+	CRC32C Algorithm = "crc32c"
+)
+
+var Algorithms = []Algorithm { SHA256, SHA384, SHA512, BLAKE2b, BLAKE3, MD5, CRC32C }
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case SHA256:
+		return sha256.New()
+	case SHA384:
+		return sha512.New384()
+	case SHA512:
+		return sha512.New()
+	case BLAKE2b:
+		h, err := blake2b.New512(nil)
+		if err != nil {
+			panic(err)
+		}
+		return h
+	case BLAKE3:
+		return blake3.New(32, nil)
+	case MD5:
+		return md5.New()
+	case CRC32C:
+		return crc32.New(crc32cTable)
+	case S3ETag:
+		return NewS3ETag(DefaultS3PartSize)
+	case HMACSHA256:
+		panic(fmt.Sprintf("keyed hash algorithm: %s", string(a)))
+	default:
+		panic(fmt.Sprintf("unsupported hash algorithm: %s", string(a)))
+	}
+}
+
+// Size returns the length in bytes of the algorithm's digests.
+func (a Algorithm) Size() int {
+	if a == HMACSHA256 {
+		return sha256.Size
+	}
+	return a.New().Size()
+}
+
+func ParseAlgorithm(s string) (Algorithm, error) {
+	a := Algorithm(strings.ToLower(s))
+	for _, b := range Algorithms {
+		if a == b {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported hash algorithm: %s", s)
+}
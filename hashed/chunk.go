@@ -0,0 +1,213 @@
+package hashed
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+)
+
+type Chunk struct {
+	Offset int64
+	Size int64
+	Digest []byte
+}
+
+type splitter interface {
+	// split returns the length of the prefix of p completing the current
+	// chunk, or -1 if the chunk continues past p
+	split(p []byte) int
+	reset()
+}
+
+// ChunkingReader hashes the content read through it in chunks, recording
+// their boundaries and digests.
+type ChunkingReader struct {
+	r io.Reader
+	alg Algorithm
+	splitter splitter
+
+	hash hash.Hash
+	offset int64
+	n int64
+
+	chunks []Chunk
+	done bool
+
+	// OnChunk, if set, is called with each chunk as soon as it is complete
+	OnChunk func(Chunk)
+}
+
+func newChunkingReader(r io.Reader, alg Algorithm, s splitter) *ChunkingReader {
+	if alg == "" {
+		alg = SHA256
+	}
+	return &ChunkingReader {
+		r: r,
+		alg: alg,
+		splitter: s,
+		hash: alg.New(),
+	}
+}
+
+func (cr *ChunkingReader) emit() {
+	c := Chunk {
+		Offset: cr.offset,
+		Size: cr.n,
+		Digest: cr.hash.Sum(nil),
+	}
+	cr.chunks = append(cr.chunks, c)
+	if cr.OnChunk != nil {
+		cr.OnChunk(c)
+	}
+
+	cr.offset += cr.n
+	cr.n = 0
+	cr.hash.Reset()
+	cr.splitter.reset()
+}
+
+func (cr *ChunkingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+
+	q := p[:n]
+	for len(q) > 0 {
+		i := cr.splitter.split(q)
+		if i < 0 {
+			_, _ = cr.hash.Write(q)
+			cr.n += int64(len(q))
+			break
+		}
+
+		_, _ = cr.hash.Write(q[:i])
+		cr.n += int64(i)
+		cr.emit()
+		q = q[i:]
+	}
+
+	if err == io.EOF && !cr.done {
+		if cr.n > 0 {
+			cr.emit()
+		}
+		cr.done = true
+	}
+
+	return n, err
+}
+
+// Chunks returns the chunks completed so far; the last chunk is only
+// included once the underlying reader has returned io.EOF.
+func (cr *ChunkingReader) Chunks() []Chunk {
+	return cr.chunks
+}
+
+func (cr *ChunkingReader) Digests() [][]byte {
+	ds := make([][]byte, len(cr.chunks))
+	for i, c := range cr.chunks {
+		ds[i] = c.Digest
+	}
+	return ds
+}
+
+func (cr *ChunkingReader) MerkleRoot() []byte {
+	return MerkleRoot(cr.alg, cr.Digests())
+}
+
+
+type fixedSplitter struct {
+	size int
+	n int
+}
+
+func (s *fixedSplitter) split(p []byte) int {
+	if r := s.size - s.n; len(p) >= r {
+		return r
+	}
+	s.n += len(p)
+	return -1
+}
+
+func (s *fixedSplitter) reset() {
+	s.n = 0
+}
+
+// ReaderFixedChunks chunks the content read into chunks of size bytes (the
+// last chunk possibly being shorter).
+func ReaderFixedChunks(r io.Reader, size int, alg Algorithm) *ChunkingReader {
+	if size <= 0 {
+		panic(fmt.Sprintf("invalid chunk size: %d", size))
+	}
+	return newChunkingReader(r, alg, &fixedSplitter { size: size })
+}
+
+
+const (
+	DefaultMinChunkSize = 16 * 1024
+	DefaultAvgChunkSize = 64 * 1024
+	DefaultMaxChunkSize = 256 * 1024
+)
+
+// gear is the table of the rolling hash used for content-defined chunking.
+// It is generated from a fixed seed since changing it changes every chunk
+// boundary.
+var gear = func() (g [256]uint64) {
+	// splitmix64
+	x := uint64(0x726f6f746d6f7321)
+	for i := range g {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		g[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+type gearSplitter struct {
+	min, max int
+	mask uint64
+
+	h uint64
+	n int
+}
+
+func (s *gearSplitter) split(p []byte) int {
+	for i, b := range p {
+		s.n += 1
+		if s.n <= s.min {
+			continue
+		}
+
+		s.h = (s.h << 1) + gear[b]
+		if s.h & s.mask == 0 || s.n >= s.max {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func (s *gearSplitter) reset() {
+	s.h = 0
+	s.n = 0
+}
+
+// ReaderContentDefinedChunks chunks the content read at boundaries chosen by
+// a rolling (gear) hash of the content, so that insertions and deletions only
+// affect the chunks around them. Chunks are between min and max bytes, and
+// avg (rounded down to a power of two) bytes longer than min on average.
+func ReaderContentDefinedChunks(r io.Reader, min, avg, max int, alg Algorithm) *ChunkingReader {
+	if min < 0 || avg <= 0 || max <= min {
+		panic(fmt.Sprintf("invalid chunk sizes: min=%d avg=%d max=%d", min, avg, max))
+	}
+
+	// use the most significant bits, the least significant ones only depend
+	// on the last few bytes
+	b := bits.Len(uint(avg)) - 1
+	mask := ^uint64(0) << (64 - b)
+
+	return newChunkingReader(r, alg, &gearSplitter {
+		min: min,
+		max: max,
+		mask: mask,
+	})
+}
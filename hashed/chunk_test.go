@@ -0,0 +1,127 @@
+package hashed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+var prng = rand.New(rand.NewSource(0x6368756e6b))
+
+func freshBytes(n int) []byte {
+	bs := make([]byte, n)
+	_, _ = prng.Read(bs)
+	return bs
+}
+
+func chunks(t *testing.T, cr *ChunkingReader) []Chunk {
+	if _, err := io.Copy(io.Discard, iotest.HalfReader(cr)); err != nil {
+		t.Fatal(err)
+	}
+	return cr.Chunks()
+}
+
+func TestFixedChunks(t *testing.T) {
+	data := freshBytes(10*1024 + 7)
+
+	var emitted int
+	cr := ReaderFixedChunks(bytes.NewReader(data), 1024, SHA256)
+	cr.OnChunk = func(Chunk) { emitted += 1 }
+	cs := chunks(t, cr)
+
+	if len(cs) != 11 || emitted != 11 {
+		t.Fatalf("unexpected number of chunks: %d (%d emitted)", len(cs), emitted)
+	}
+
+	for _, c := range cs {
+		sum := sha256.Sum256(data[c.Offset:c.Offset+c.Size])
+		if !bytes.Equal(c.Digest, sum[:]) {
+			t.Errorf("unexpected digest of chunk at %d", c.Offset)
+		}
+	}
+	if cs[10].Size != 7 {
+		t.Errorf("unexpected size of last chunk: %d", cs[10].Size)
+	}
+}
+
+func TestContentDefinedChunks(t *testing.T) {
+	data := freshBytes(1024*1024)
+	cs0 := chunks(t, ReaderContentDefinedChunks(bytes.NewReader(data), 1024, 8*1024, 32*1024, SHA256))
+
+	var offset int64
+	for _, c := range cs0 {
+		if c.Offset != offset {
+			t.Fatalf("unexpected chunk offset: %d != %d", c.Offset, offset)
+		}
+		if c.Size > 32*1024 || (c.Size < 1024 && c.Offset + c.Size != int64(len(data))) {
+			t.Errorf("unexpected chunk size: %d", c.Size)
+		}
+		offset += c.Size
+	}
+	if offset != int64(len(data)) {
+		t.Fatalf("chunks do not cover content: %d != %d", offset, len(data))
+	}
+
+	// an insertion only affects the chunks around it
+	edited := append(append(bytes.Clone(data[:300*1024]), freshBytes(100)...), data[300*1024:]...)
+	cs1 := chunks(t, ReaderContentDefinedChunks(bytes.NewReader(edited), 1024, 8*1024, 32*1024, SHA256))
+
+	seen := make(map[string]bool)
+	for _, c := range cs0 {
+		seen[string(c.Digest)] = true
+	}
+	var changed int
+	for _, c := range cs1 {
+		if !seen[string(c.Digest)] {
+			changed += 1
+		}
+	}
+	if changed > 2 {
+		t.Errorf("too many chunks changed by insertion: %d of %d", changed, len(cs1))
+	}
+}
+
+func TestMerkle(t *testing.T) {
+	// the root of the empty leaf, as in RFC 6962's test vectors
+	if root := hex.EncodeToString(MerkleRoot(SHA256, [][]byte { {} })); root != "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d" {
+		t.Errorf("unexpected root of the empty leaf: %s", root)
+	}
+
+	a, b := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b"))
+	la := sha256.Sum256(append([]byte { 0x00 }, a[:]...))
+	lb := sha256.Sum256(append([]byte { 0x00 }, b[:]...))
+	expected := sha256.Sum256(append(append([]byte { 0x01 }, la[:]...), lb[:]...))
+	root := MerkleRoot(SHA256, [][]byte { a[:], b[:] })
+	if !bytes.Equal(root, expected[:]) {
+		t.Errorf("unexpected root: %x", root)
+	}
+
+	// the node of the leaves' hashes is not a leaf itself
+	node := append(append([]byte { 0x01 }, la[:]...), lb[:]...)
+	if VerifyMerkleProof(SHA256, root, node, 0, 1, nil) || bytes.Equal(MerkleRoot(SHA256, [][]byte { node }), root) {
+		t.Errorf("interior node accepted as a leaf")
+	}
+
+	for n := 1; n <= 9; n++ {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			sum := sha256.Sum256(freshBytes(8))
+			leaves[i] = sum[:]
+		}
+		root := MerkleRoot(SHA256, leaves)
+
+		for i := range leaves {
+			proof := MerkleProof(SHA256, leaves, i)
+			if !VerifyMerkleProof(SHA256, root, leaves[i], i, n, proof) {
+				t.Errorf("unable to verify leaf %d of %d", i, n)
+			}
+			if n > 1 && VerifyMerkleProof(SHA256, root, leaves[(i+1)%n], i, n, proof) {
+				t.Errorf("verified incorrect leaf %d of %d", i, n)
+			}
+		}
+	}
+}
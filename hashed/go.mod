@@ -1,3 +1,13 @@
 module rootmos.io/go-utils/hashed
 
 go 1.21.5
+
+require (
+	golang.org/x/crypto v0.21.0
+	lukechampine.com/blake3 v1.2.1
+)
+
+require (
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+)
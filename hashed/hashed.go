@@ -1,29 +1,70 @@
 package hashed
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"hash"
 	"io"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/base64"
+	"io/fs"
 )
 
 type ReaderHashed struct {
 	io.Reader
-	hash hash.Hash
+	*hashes
+
+	r io.Reader
+}
+
+func newReaderHashed(r io.Reader, hs *hashes) *ReaderHashed {
+	return &ReaderHashed {
+		Reader: io.TeeReader(r, hs.writer()),
+		hashes: hs,
+		r: r,
+	}
 }
 
 func ReaderSHA256(r io.Reader) (rh *ReaderHashed) {
-	h := sha256.New()
+	return Reader(r, SHA256)
+}
 
-	return &ReaderHashed {
-		Reader: io.TeeReader(r, h),
-		hash: h,
+// Reader hashes everything read from r using each of the algorithms (SHA256
+// if none are given) in a single pass.
+func Reader(r io.Reader, algs ...Algorithm) (rh *ReaderHashed) {
+	return newReaderHashed(r, newHashes(algs))
+}
+
+func (rh *ReaderHashed) Read(p []byte) (int, error) {
+	if rh.frozen != nil {
+		return 0, fs.ErrClosed
 	}
+	return rh.Reader.Read(p)
 }
 
-func (rh *ReaderHashed) Digest() []byte {
-	return rh.hash.Sum(nil)
+// WriteTo lets the inner reader write directly into w and the hashes when
+// it implements io.WriterTo, avoiding an intermediate buffer.
+func (rh *ReaderHashed) WriteTo(w io.Writer) (int64, error) {
+	if rh.frozen != nil {
+		return 0, fs.ErrClosed
+	}
+
+	if wt, ok := rh.r.(io.WriterTo); ok {
+		return wt.WriteTo(io.MultiWriter(w, rh.writer()))
+	}
+	return io.Copy(w, rh.Reader)
+}
+
+// Close freezes the digests and closes the inner reader if it is an
+// io.Closer. Reading after Close fails with fs.ErrClosed.
+func (rh *ReaderHashed) Close() error {
+	if rh.frozen != nil {
+		return nil
+	}
+	rh.freeze()
+
+	if c, ok := rh.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 func (rh *ReaderHashed) HexDigest() string {
@@ -37,20 +78,62 @@ func (rh *ReaderHashed) B64Digest() string {
 
 type WriterHashed struct {
 	io.Writer
-	hash hash.Hash
+	*hashes
+
+	w io.Writer
+}
+
+func newWriterHashed(w io.Writer, hs *hashes) *WriterHashed {
+	return &WriterHashed {
+		Writer: io.MultiWriter(w, hs.writer()),
+		hashes: hs,
+		w: w,
+	}
 }
 
 func WriterSHA256(w io.Writer) (wh *WriterHashed) {
-	h := sha256.New()
+	return Writer(w, SHA256)
+}
 
-	return &WriterHashed {
-		Writer: io.MultiWriter(w, h),
-		hash: h,
+// Writer hashes everything written to w using each of the algorithms (SHA256
+// if none are given) in a single pass.
+func Writer(w io.Writer, algs ...Algorithm) (wh *WriterHashed) {
+	return newWriterHashed(w, newHashes(algs))
+}
+
+func (wh *WriterHashed) Write(p []byte) (int, error) {
+	if wh.frozen != nil {
+		return 0, fs.ErrClosed
 	}
+	return wh.Writer.Write(p)
 }
 
-func (wh *WriterHashed) Digest() []byte {
-	return wh.hash.Sum(nil)
+// ReadFrom lets the inner writer read directly when it implements
+// io.ReaderFrom, and otherwise lets r write directly into the inner writer
+// and the hashes when r implements io.WriterTo.
+func (wh *WriterHashed) ReadFrom(r io.Reader) (int64, error) {
+	if wh.frozen != nil {
+		return 0, fs.ErrClosed
+	}
+
+	if rf, ok := wh.w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(io.TeeReader(r, wh.writer()))
+	}
+	return io.Copy(wh.Writer, r)
+}
+
+// Close freezes the digests and closes the inner writer if it is an
+// io.Closer. Writing after Close fails with fs.ErrClosed.
+func (wh *WriterHashed) Close() error {
+	if wh.frozen != nil {
+		return nil
+	}
+	wh.freeze()
+
+	if c, ok := wh.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 func (wh *WriterHashed) HexDigest() string {
@@ -60,3 +143,93 @@ func (wh *WriterHashed) HexDigest() string {
 func (wh *WriterHashed) B64Digest() string {
 	return base64.StdEncoding.EncodeToString(wh.Digest())
 }
+
+
+type hashes struct {
+	algs []Algorithm
+	hs []hash.Hash
+
+	n int64
+
+	// frozen holds the digests once closed
+	frozen [][]byte
+}
+
+func newHashes(algs []Algorithm) *hashes {
+	if len(algs) == 0 {
+		algs = []Algorithm { SHA256 }
+	}
+
+	hs := make([]hash.Hash, len(algs))
+	for i, a := range algs {
+		hs[i] = a.New()
+	}
+
+	return &hashes {
+		algs: algs,
+		hs: hs,
+	}
+}
+
+func newHashesWith(alg Algorithm, h hash.Hash, algs []Algorithm) *hashes {
+	hs := &hashes {
+		algs: []Algorithm { alg },
+		hs: []hash.Hash { h },
+	}
+
+	for _, a := range algs {
+		hs.algs = append(hs.algs, a)
+		hs.hs = append(hs.hs, a.New())
+	}
+
+	return hs
+}
+
+type hashesWriter struct {
+	*hashes
+}
+
+func (w hashesWriter) Write(p []byte) (int, error) {
+	for _, h := range w.hs {
+		_, _ = h.Write(p)
+	}
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func (hs *hashes) writer() io.Writer {
+	return hashesWriter { hs }
+}
+
+func (hs *hashes) freeze() {
+	hs.frozen = make([][]byte, len(hs.hs))
+	for i, h := range hs.hs {
+		hs.frozen[i] = h.Sum(nil)
+	}
+}
+
+func (hs *hashes) sum(i int) []byte {
+	if hs.frozen != nil {
+		return hs.frozen[i]
+	}
+	return hs.hs[i].Sum(nil)
+}
+
+// Digest returns the digest of the first algorithm.
+func (hs *hashes) Digest() []byte {
+	return hs.sum(0)
+}
+
+// Size returns the number of bytes hashed so far.
+func (hs *hashes) Size() int64 {
+	return hs.n
+}
+
+// Digests returns the digests computed so far keyed by algorithm.
+func (hs *hashes) Digests() map[Algorithm][]byte {
+	ds := make(map[Algorithm][]byte, len(hs.algs))
+	for i, a := range hs.algs {
+		ds[a] = hs.sum(i)
+	}
+	return ds
+}
@@ -0,0 +1,381 @@
+package hashed
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// digests of "abc"
+var abc = map[Algorithm]string {
+	SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	SHA384: "cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7",
+	SHA512: "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f",
+	BLAKE2b: "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+	BLAKE3: "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85",
+	MD5: "900150983cd24fb0d6963f7d28e17f72",
+	CRC32C: "364b3fb7",
+}
+
+func TestReaderDigests(t *testing.T) {
+	rh := Reader(strings.NewReader("abc"), Algorithms...)
+	if _, err := io.Copy(io.Discard, rh); err != nil {
+		t.Fatal(err)
+	}
+
+	ds := rh.Digests()
+	for _, a := range Algorithms {
+		if hex.EncodeToString(ds[a]) != abc[a] {
+			t.Errorf("unexpected %s digest: %x", a, ds[a])
+		}
+	}
+
+	if rh.HexDigest() != abc[SHA256] {
+		t.Errorf("unexpected digest: %s", rh.HexDigest())
+	}
+}
+
+func TestWriterDigests(t *testing.T) {
+	var buf bytes.Buffer
+	wh := Writer(&buf, MD5, BLAKE3)
+	if _, err := io.WriteString(wh, "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "abc" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	ds := wh.Digests()
+	if len(ds) != 2 || hex.EncodeToString(ds[BLAKE3]) != abc[BLAKE3] {
+		t.Errorf("unexpected digests: %v", ds)
+	}
+
+	if wh.HexDigest() != abc[MD5] {
+		t.Errorf("unexpected digest: %s", wh.HexDigest())
+	}
+}
+
+func TestS3ETag(t *testing.T) {
+	data := []byte("0123456789abc")
+
+	var ds []byte
+	for _, p := range [][]byte { data[0:5], data[5:10], data[10:] } {
+		d := md5.Sum(p)
+		ds = append(ds, d[:]...)
+	}
+	d := md5.Sum(ds)
+	expected := hex.EncodeToString(d[:]) + "-3"
+
+	rh := ReaderS3ETag(bytes.NewReader(data), 5, MD5)
+	if _, err := io.Copy(io.Discard, iotest.OneByteReader(rh)); err != nil {
+		t.Fatal(err)
+	}
+	if rh.ETag() != expected {
+		t.Errorf("unexpected ETag: %s != %s", rh.ETag(), expected)
+	}
+
+	single := md5.Sum(data)
+	if !bytes.Equal(rh.Digests()[MD5], single[:]) {
+		t.Errorf("unexpected MD5 digest")
+	}
+
+	h := NewS3ETag(0)
+	_, _ = h.Write(data)
+	if !h.Matches(`"` + hex.EncodeToString(single[:]) + `"`) {
+		t.Errorf("unexpected single part ETag: %s", h.ETag())
+	}
+
+	// content ending on a part boundary has no empty last part
+	h = NewS3ETag(5)
+	_, _ = h.Write(data[:10])
+	if h.Parts() != 2 {
+		t.Errorf("unexpected number of parts: %d", h.Parts())
+	}
+}
+
+func TestGuessS3PartSize(t *testing.T) {
+	const MiB = 1024 * 1024
+	etag := `"d41d8cd98f00b204e9800998ecf8427e-3"`
+
+	for _, c := range []struct{ size, partSize int64 } {
+		{ 20*MiB + 1, DefaultS3PartSize },
+		{ 11*MiB, 5*MiB },
+		{ 1000*MiB, 334*MiB },
+	} {
+		ps, err := GuessS3PartSize(etag, c.size)
+		if err != nil {
+			t.Errorf("unable to guess part size of %d: %v", c.size, err)
+		} else if ps != c.partSize {
+			t.Errorf("unexpected part size of %d: %d != %d", c.size, ps, c.partSize)
+		}
+	}
+
+	if ps, err := GuessS3PartSize("d41d8cd98f00b204e9800998ecf8427e", 7); err != nil || ps != 0 {
+		t.Errorf("unexpected part size of single part ETag: %d %v", ps, err)
+	}
+
+	if _, err := GuessS3PartSize(etag, 1); err == nil {
+		t.Errorf("unexpected success guessing impossible part size")
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	bs, _ := hex.DecodeString(abc[SHA256])
+	for _, expected := range []string {
+		abc[SHA256],
+		string(bs),
+		base64.StdEncoding.EncodeToString(bs),
+		base64.RawURLEncoding.EncodeToString(bs),
+		"sha256-" + base64.StdEncoding.EncodeToString(bs),
+	} {
+		vr, err := NewVerifyingReader(strings.NewReader("abc"), SHA256, expected)
+		if err != nil {
+			t.Fatalf("unable to parse digest %q: %v", expected, err)
+		}
+		if _, err := io.ReadAll(vr); err != nil {
+			t.Errorf("unexpected error verifying %q: %v", expected, err)
+		}
+
+		vr, _ = NewVerifyingReader(strings.NewReader("abd"), SHA256, expected)
+		if _, err := io.ReadAll(vr); !errors.Is(err, ErrDigestMismatch) {
+			t.Errorf("unexpected error verifying %q: %v", expected, err)
+		}
+	}
+
+	vr, err := NewVerifyingReaderDigest(strings.NewReader("abc"), SHA256, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(vr); err != nil {
+		t.Errorf("unexpected error verifying raw digest: %v", err)
+	}
+	if _, err := NewVerifyingReaderDigest(strings.NewReader("abc"), SHA256, bs[1:]); err == nil {
+		t.Errorf("unexpected success with truncated digest")
+	}
+
+	sri := "sha512-" + base64.StdEncoding.EncodeToString(func() []byte {
+		bs, _ := hex.DecodeString(abc[SHA512])
+		return bs
+	}())
+	vr, err = NewVerifyingReader(strings.NewReader("ab"), "", sri)
+	if err != nil {
+		t.Fatalf("unable to parse SRI digest: %v", err)
+	}
+	if _, err := io.ReadAll(vr); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("truncated stream not detected: %v", err)
+	}
+}
+
+func TestManifestRoundtrip(t *testing.T) {
+	sha, _ := hex.DecodeString(abc[SHA256])
+	md, _ := hex.DecodeString(abc[MD5])
+	m0 := Manifest {
+		{ Algorithm: SHA256, Digest: sha, Path: "foo/bar" },
+		{ Algorithm: SHA256, Digest: sha, Path: "with\nnewline and \\", Binary: true },
+		{ Algorithm: SHA256, Digest: sha, Path: "paren (1).txt" },
+	}
+
+	for _, f := range []ManifestFormat { ManifestGNU, ManifestBSD } {
+		var buf bytes.Buffer
+		if err := WriteManifest(&buf, m0, f); err != nil {
+			t.Fatal(err)
+		}
+
+		m1, err := ReadManifest(&buf, SHA256)
+		if err != nil {
+			t.Fatalf("unable to read manifest: %v", err)
+		}
+
+		for i := range m0 {
+			if f == ManifestBSD {
+				m1[i].Binary = m0[i].Binary
+			}
+			if !reflect.DeepEqual(m0[i], m1[i]) {
+				t.Errorf("unexpected entry: %v != %v", m1[i], m0[i])
+			}
+		}
+	}
+
+	m, err := ReadManifest(strings.NewReader(
+		"# comment\n" +
+		abc[MD5] + "  a\n" +
+		"MD5 (b) = " + abc[MD5] + "\n",
+	), "")
+	if err != nil {
+		t.Fatalf("unable to read manifest: %v", err)
+	}
+	if len(m) != 2 || m[0].Algorithm != MD5 || m[1].Algorithm != MD5 || m[1].Path != "b" || !bytes.Equal(m[0].Digest, md) {
+		t.Errorf("unexpected manifest: %v", m)
+	}
+}
+
+func TestManifestCheck(t *testing.T) {
+	sha, _ := hex.DecodeString(abc[SHA256])
+	m := Manifest {
+		{ Algorithm: SHA256, Digest: sha, Path: "ok" },
+		{ Algorithm: SHA256, Digest: sha, Path: "bad" },
+		{ Algorithm: SHA256, Digest: sha, Path: "missing" },
+	}
+
+	rs := m.Check(func(path string) (io.ReadCloser, error) {
+		switch path {
+		case "ok":
+			return io.NopCloser(strings.NewReader("abc")), nil
+		case "bad":
+			return io.NopCloser(strings.NewReader("abd")), nil
+		default:
+			return nil, fs.ErrNotExist
+		}
+	}, nil)
+
+	expected := []CheckStatus { CheckOK, CheckMismatch, CheckMissing }
+	for i, r := range rs {
+		if r.Status != expected[i] {
+			t.Errorf("unexpected status of %s: %s", r.Path, r.Status)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	data := freshBytes(4096)
+	algs := []Algorithm { SHA512, MD5, CRC32C, BLAKE2b }
+
+	wh0 := WriterS3ETag(io.Discard, 1000, algs...)
+	if _, err := wh0.Write(data[:2500]); err != nil {
+		t.Fatal(err)
+	}
+	st, err := wh0.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal state: %v", err)
+	}
+
+	rh := ReaderS3ETag(bytes.NewReader(data[2500:]), 1000, algs...)
+	if err := rh.UnmarshalBinary(st); err != nil {
+		t.Fatalf("unable to unmarshal state: %v", err)
+	}
+	if rh.Size() != 2500 {
+		t.Errorf("unexpected size after resume: %d", rh.Size())
+	}
+	if _, err := io.Copy(io.Discard, rh); err != nil {
+		t.Fatal(err)
+	}
+
+	wh1 := WriterS3ETag(io.Discard, 1000, algs...)
+	if _, err := wh1.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rh.Digests(), wh1.Digests()) || rh.ETag() != wh1.ETag() || rh.Size() != wh1.Size() {
+		t.Errorf("resumed digests differ")
+	}
+
+	if err := Reader(nil, SHA256).UnmarshalBinary(st); err == nil {
+		t.Errorf("unexpected success unmarshaling state of other algorithms")
+	}
+	if _, err := Reader(nil, BLAKE3).MarshalBinary(); err == nil {
+		t.Errorf("unexpected success marshaling BLAKE3 state")
+	}
+}
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed += 1
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	var c closeRecorder
+	wh := Writer(&c, SHA256, MD5)
+
+	// bytes.Buffer implements io.ReaderFrom and strings.Reader io.WriterTo
+	if _, err := io.Copy(wh, strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if wh.Size() != 3 || c.String() != "abc" {
+		t.Errorf("unexpected size or output: %d %q", wh.Size(), c.String())
+	}
+
+	if err := wh.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wh.Close(); err != nil || c.closed != 1 {
+		t.Errorf("unexpected second close: %v (closed %d times)", err, c.closed)
+	}
+
+	if _, err := wh.Write([]byte("d")); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("unexpected write after close: %v", err)
+	}
+	if wh.HexDigest() != abc[SHA256] || hex.EncodeToString(wh.Digests()[MD5]) != abc[MD5] {
+		t.Errorf("unexpected digest after close: %s", wh.HexDigest())
+	}
+	if err := wh.UnmarshalBinary(nil); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("unexpected unmarshal after close: %v", err)
+	}
+
+	rh := Reader(iotest.OneByteReader(&c))
+	var buf bytes.Buffer
+	if _, err := rh.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := rh.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "abc" || rh.Size() != 3 || rh.HexDigest() != abc[SHA256] {
+		t.Errorf("unexpected read: %q %d %s", buf.String(), rh.Size(), rh.HexDigest())
+	}
+	if _, err := rh.Read(make([]byte, 1)); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("unexpected read after close: %v", err)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	// RFC 4231 test case 2
+	key := RawKey("Jefe")
+	data := "what do ya want for nothing?"
+	mac := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+
+	var buf bytes.Buffer
+	wh := WriterHMACSHA256(&buf, key, SHA256)
+	if _, err := io.WriteString(wh, data); err != nil {
+		t.Fatal(err)
+	}
+	if wh.HexDigest() != mac {
+		t.Errorf("unexpected MAC: %s", wh.HexDigest())
+	}
+	expected, _ := hex.DecodeString(mac)
+	if !wh.Verify(expected) || !VerifyHMACSHA256(key, buf.Bytes(), wh.Digest()) {
+		t.Errorf("unable to verify MAC")
+	}
+	if VerifyHMACSHA256(RawKey("jefe"), buf.Bytes(), wh.Digest()) {
+		t.Errorf("unexpected verification using another key")
+	}
+
+	vr, err := NewVerifyingReaderHMACSHA256(strings.NewReader(data), key, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, vr); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	vr, err = NewVerifyingReaderHMACSHA256(strings.NewReader(data + "!"), key, mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, vr); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package hashed
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// HMACSHA256 is keyed and can therefore not be instantiated using
+// Algorithm.New, use ReaderHMACSHA256 or WriterHMACSHA256 instead.
+const HMACSHA256 Algorithm = "hmac-sha256"
+
+// Key is the secret of an HMAC, e.g. a *sealedbox.Key or a RawKey.
+type Key interface {
+	Bytes() []byte
+}
+
+type RawKey []byte
+
+func (k RawKey) Bytes() []byte {
+	return k
+}
+
+// ReaderHMACSHA256 authenticates everything read from r using HMAC-SHA256,
+// in addition to hashing it using any other algorithms.
+func ReaderHMACSHA256(r io.Reader, key Key, algs ...Algorithm) (rh *ReaderHashed) {
+	hs := newHashesWith(HMACSHA256, hmac.New(sha256.New, key.Bytes()), algs)
+
+	return newReaderHashed(r, hs)
+}
+
+func WriterHMACSHA256(w io.Writer, key Key, algs ...Algorithm) (wh *WriterHashed) {
+	hs := newHashesWith(HMACSHA256, hmac.New(sha256.New, key.Bytes()), algs)
+
+	return newWriterHashed(w, hs)
+}
+
+// Equal compares digests or MACs in constant time.
+func Equal(a, b []byte) bool {
+	return hmac.Equal(a, b)
+}
+
+// Verify checks in constant time that the digest (or MAC) of the first
+// algorithm matches the expected one.
+func (hs *hashes) Verify(expected []byte) bool {
+	return Equal(hs.Digest(), expected)
+}
+
+// HMACSHA256Sum returns the HMAC-SHA256 of data.
+func HMACSHA256Sum(key Key, data []byte) []byte {
+	h := hmac.New(sha256.New, key.Bytes())
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+// VerifyHMACSHA256 checks in constant time that mac authenticates data.
+func VerifyHMACSHA256(key Key, data, mac []byte) bool {
+	return Equal(HMACSHA256Sum(key, data), mac)
+}
+
+// NewVerifyingReaderHMACSHA256 is the authenticating counterpart of
+// NewVerifyingReader: the returned reader fails with ErrDigestMismatch
+// at EOF unless expected (in any form accepted by ParseDigest) is the
+// HMAC-SHA256 of the content read.
+func NewVerifyingReaderHMACSHA256(r io.Reader, key Key, expected string) (*VerifyingReader, error) {
+	alg, mac, err := ParseDigest(HMACSHA256, expected)
+	if err != nil {
+		return nil, err
+	}
+	if alg != HMACSHA256 {
+		return nil, fmt.Errorf("unexpected algorithm of MAC: %s", alg)
+	}
+
+	return &VerifyingReader {
+		ReaderHashed: ReaderHMACSHA256(r, key),
+		expected: mac,
+	}, nil
+}
@@ -0,0 +1,228 @@
+package hashed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ManifestEntry is a line of a checksum file as written by e.g. sha256sum,
+// either in the GNU ("<hex>  <path>") or in the BSD ("SHA256 (<path>) = <hex>")
+// format.
+type ManifestEntry struct {
+	Algorithm Algorithm
+	Digest []byte
+	Path string
+
+	// Binary is the GNU format's binary mode marker ("<hex> *<path>")
+	Binary bool
+}
+
+type Manifest []ManifestEntry
+
+type ManifestFormat int
+
+const (
+	ManifestGNU ManifestFormat = iota
+	ManifestBSD
+)
+
+func bsdTag(alg Algorithm) string {
+	switch alg {
+	case BLAKE2b:
+		return "BLAKE2b"
+	default:
+		return strings.ToUpper(string(alg))
+	}
+}
+
+var manifestEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+var manifestUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r")
+
+func (e *ManifestEntry) Format(f ManifestFormat) string {
+	p := manifestEscaper.Replace(e.Path)
+	var prefix string
+	if p != e.Path {
+		prefix = "\\"
+	}
+
+	switch f {
+	case ManifestBSD:
+		return fmt.Sprintf("%s%s (%s) = %s", prefix, bsdTag(e.Algorithm), p, hex.EncodeToString(e.Digest))
+	default:
+		mode := " "
+		if e.Binary {
+			mode = "*"
+		}
+		return fmt.Sprintf("%s%s %s%s", prefix, hex.EncodeToString(e.Digest), mode, p)
+	}
+}
+
+func WriteManifest(w io.Writer, m Manifest, f ManifestFormat) error {
+	for _, e := range m {
+		if _, err := fmt.Fprintln(w, e.Format(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func algorithmFromHexLength(l int) (Algorithm, error) {
+	switch l {
+	case 2*4:
+		return CRC32C, nil
+	case 2*16:
+		return MD5, nil
+	case 2*32:
+		return SHA256, nil
+	case 2*48:
+		return SHA384, nil
+	case 2*64:
+		return SHA512, nil
+	default:
+		return "", fmt.Errorf("unable to determine algorithm of digest of length %d", l)
+	}
+}
+
+func parseManifestLine(line string, alg Algorithm) (e ManifestEntry, err error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	var digest string
+	if tag, rest, ok := strings.Cut(line, " ("); ok && !strings.ContainsAny(tag, " *") {
+		i := strings.LastIndex(rest, ") = ")
+		if i < 0 {
+			return e, fmt.Errorf("malformed BSD style line: %q", line)
+		}
+		if e.Algorithm, err = ParseAlgorithm(tag); err != nil {
+			return e, err
+		}
+		e.Path, digest = rest[:i], rest[i+4:]
+	} else {
+		var rest string
+		if digest, rest, ok = strings.Cut(line, " "); !ok || rest == "" {
+			return e, fmt.Errorf("malformed GNU style line: %q", line)
+		}
+		e.Binary = rest[0] == '*'
+		e.Path = rest[1:]
+
+		e.Algorithm = alg
+		if e.Algorithm == "" {
+			if e.Algorithm, err = algorithmFromHexLength(len(digest)); err != nil {
+				return e, err
+			}
+		}
+	}
+
+	if escaped {
+		e.Path = manifestUnescaper.Replace(e.Path)
+	}
+
+	if e.Digest, err = hex.DecodeString(digest); err != nil {
+		return e, fmt.Errorf("malformed digest: %q", digest)
+	}
+	if len(e.Digest) != e.Algorithm.Size() {
+		return e, fmt.Errorf("unexpected %s digest length: %d", e.Algorithm, len(e.Digest))
+	}
+
+	return e, nil
+}
+
+// ReadManifest parses GNU and BSD style lines, where alg is the algorithm
+// of the GNU style lines (guessed from the digests' lengths if empty).
+func ReadManifest(r io.Reader, alg Algorithm) (m Manifest, err error) {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		e, err := parseManifestLine(line, alg)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		m = append(m, e)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+type CheckStatus int
+
+const (
+	CheckOK CheckStatus = iota
+	CheckMismatch
+	CheckMissing
+	CheckError
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckMismatch:
+		return "FAILED"
+	case CheckMissing:
+		return "MISSING"
+	default:
+		return "ERROR"
+	}
+}
+
+type CheckResult struct {
+	ManifestEntry
+	Status CheckStatus
+	Actual []byte
+	Err error
+}
+
+// Check verifies every entry of the manifest using open to read its path.
+// Missing paths are recognized using isNotExist, or fs.ErrNotExist if nil.
+func (m Manifest) Check(open func(path string) (io.ReadCloser, error), isNotExist func(error) bool) (rs []CheckResult) {
+	if isNotExist == nil {
+		isNotExist = func(err error) bool {
+			return errors.Is(err, fs.ErrNotExist)
+		}
+	}
+
+	for _, e := range m {
+		r := CheckResult { ManifestEntry: e }
+
+		rc, err := open(e.Path)
+		if err != nil {
+			r.Err = err
+			if isNotExist(err) {
+				r.Status = CheckMissing
+			} else {
+				r.Status = CheckError
+			}
+			rs = append(rs, r)
+			continue
+		}
+
+		rh := Reader(rc, e.Algorithm)
+		_, err = io.Copy(io.Discard, rh)
+		rc.Close()
+		if err != nil {
+			r.Status, r.Err = CheckError, err
+		} else if r.Actual = rh.Digest(); !bytes.Equal(r.Actual, e.Digest) {
+			r.Status = CheckMismatch
+		}
+
+		rs = append(rs, r)
+	}
+
+	return
+}
@@ -0,0 +1,93 @@
+package hashed
+
+import (
+	"bytes"
+)
+
+// The Merkle tree is built as in RFC 6962 (Certificate Transparency) with the
+// leaves being the given digests (e.g. of chunks): leaves are hashed as
+// H(0x00 || leaf) and interior nodes as H(0x01 || left || right), so that a
+// node can not be presented as a leaf. The root of no leaves is the digest of
+// the empty string.
+
+func merkleLeaf(alg Algorithm, leaf []byte) []byte {
+	h := alg.New()
+	_, _ = h.Write([]byte { 0x00 })
+	_, _ = h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func merkleNode(alg Algorithm, l, r []byte) []byte {
+	h := alg.New()
+	_, _ = h.Write([]byte { 0x01 })
+	_, _ = h.Write(l)
+	_, _ = h.Write(r)
+	return h.Sum(nil)
+}
+
+// merkleSplit returns the largest power of two smaller than n
+func merkleSplit(n int) int {
+	k := 1
+	for k << 1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func MerkleRoot(alg Algorithm, leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return alg.New().Sum(nil)
+	case 1:
+		return merkleLeaf(alg, leaves[0])
+	}
+
+	k := merkleSplit(len(leaves))
+	return merkleNode(alg, MerkleRoot(alg, leaves[:k]), MerkleRoot(alg, leaves[k:]))
+}
+
+// MerkleProof returns the audit path proving that the i:th leaf is included
+// in the tree of the leaves.
+func MerkleProof(alg Algorithm, leaves [][]byte, i int) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := merkleSplit(len(leaves))
+	if i < k {
+		return append(MerkleProof(alg, leaves[:k], i), MerkleRoot(alg, leaves[k:]))
+	} else {
+		return append(MerkleProof(alg, leaves[k:], i - k), MerkleRoot(alg, leaves[:k]))
+	}
+}
+
+// VerifyMerkleProof checks that leaf is the i:th of n leaves of the tree with
+// the given root, allowing a single chunk to be verified without the others.
+func VerifyMerkleProof(alg Algorithm, root, leaf []byte, i, n int, proof [][]byte) bool {
+	if i < 0 || i >= n {
+		return false
+	}
+
+	var f func(i, n int, proof [][]byte) ([]byte, bool)
+	f = func(i, n int, proof [][]byte) ([]byte, bool) {
+		if n == 1 {
+			return merkleLeaf(alg, leaf), len(proof) == 0
+		}
+		if len(proof) == 0 {
+			return nil, false
+		}
+
+		sibling := proof[len(proof)-1]
+		k := merkleSplit(n)
+		if i < k {
+			l, ok := f(i, k, proof[:len(proof)-1])
+			return merkleNode(alg, l, sibling), ok
+		} else {
+			r, ok := f(i - k, n - k, proof[:len(proof)-1])
+			return merkleNode(alg, sibling, r), ok
+		}
+	}
+
+	r, ok := f(i, n, proof)
+	return ok && bytes.Equal(r, root)
+}
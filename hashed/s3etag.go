@@ -0,0 +1,216 @@
+package hashed
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const S3ETag Algorithm = "s3etag"
+
+// DefaultS3PartSize is the part size used by the AWS CLI, and by S3ETag.New.
+const DefaultS3PartSize = 8 * 1024 * 1024
+
+// S3ETagHash computes the ETag S3 assigns to an object uploaded in parts of
+// partSize bytes: the MD5 of the concatenated MD5s of the parts, suffixed by
+// the number of parts. A non-positive partSize denotes a single PutObject,
+// for which the ETag is the plain MD5 of the content.
+type S3ETagHash struct {
+	partSize int64
+
+	part hash.Hash
+	n int64
+
+	digests []byte
+	count int
+}
+
+func NewS3ETag(partSize int64) *S3ETagHash {
+	return &S3ETagHash {
+		partSize: partSize,
+		part: md5.New(),
+	}
+}
+
+func (h *S3ETagHash) PartSize() int64 {
+	return h.partSize
+}
+
+func (h *S3ETagHash) Write(p []byte) (int, error) {
+	if h.partSize <= 0 {
+		h.n += int64(len(p))
+		return h.part.Write(p)
+	}
+
+	l := len(p)
+	for len(p) > 0 {
+		// a part is only finished when more data arrives, so that content
+		// ending on a part boundary does not produce an empty last part
+		if h.n == h.partSize {
+			h.digests = h.part.Sum(h.digests)
+			h.count += 1
+			h.part.Reset()
+			h.n = 0
+		}
+
+		k := int64(len(p))
+		if r := h.partSize - h.n; k > r {
+			k = r
+		}
+
+		_, _ = h.part.Write(p[:k])
+		h.n += k
+		p = p[k:]
+	}
+	return l, nil
+}
+
+func (h *S3ETagHash) sum() (digest []byte, parts int) {
+	if h.partSize <= 0 {
+		return h.part.Sum(nil), 0
+	}
+
+	ds := h.digests
+	parts = h.count
+	if h.n > 0 || parts == 0 {
+		ds = h.part.Sum(ds[:len(ds):len(ds)])
+		parts += 1
+	}
+
+	d := md5.Sum(ds)
+	return d[:], parts
+}
+
+func (h *S3ETagHash) Sum(b []byte) []byte {
+	d, _ := h.sum()
+	return append(b, d...)
+}
+
+func (h *S3ETagHash) Reset() {
+	h.part.Reset()
+	h.n = 0
+	h.digests = nil
+	h.count = 0
+}
+
+func (h *S3ETagHash) Size() int {
+	return md5.Size
+}
+
+func (h *S3ETagHash) BlockSize() int {
+	return h.part.BlockSize()
+}
+
+// Parts returns the number of parts hashed so far, or 0 for a single PutObject.
+func (h *S3ETagHash) Parts() int {
+	_, parts := h.sum()
+	return parts
+}
+
+// ETag returns the unquoted ETag of the content hashed so far.
+func (h *S3ETagHash) ETag() string {
+	d, parts := h.sum()
+	if parts == 0 {
+		return hex.EncodeToString(d)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(d), parts)
+}
+
+// Matches compares against an ETag as returned by S3, i.e. possibly quoted.
+func (h *S3ETagHash) Matches(etag string) bool {
+	return strings.EqualFold(strings.Trim(etag, `"`), h.ETag())
+}
+
+// ParseS3ETag splits an ETag into its digest and number of parts (0 for
+// objects not uploaded in parts).
+func ParseS3ETag(etag string) (digest []byte, parts int, err error) {
+	etag = strings.Trim(etag, `"`)
+
+	d, p, multipart := strings.Cut(etag, "-")
+	if multipart {
+		if parts, err = strconv.Atoi(p); err != nil || parts < 1 {
+			return nil, 0, fmt.Errorf("invalid number of parts in ETag: %s", etag)
+		}
+	}
+
+	if digest, err = hex.DecodeString(d); err != nil || len(digest) != md5.Size {
+		return nil, 0, fmt.Errorf("invalid ETag digest: %s", etag)
+	}
+
+	return
+}
+
+var commonS3PartSizes = []int64 {
+	DefaultS3PartSize,
+	5 * 1024 * 1024,
+	16 * 1024 * 1024,
+	64 * 1024 * 1024,
+	100 * 1024 * 1024,
+}
+
+func s3Parts(size, partSize int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + partSize - 1) / partSize
+}
+
+// GuessS3PartSize guesses the part size used to upload an object of the
+// given size from the number of parts in its ETag, preferring the defaults of
+// common tools and otherwise the smallest whole number of MiB. Returns 0 for
+// ETags of objects not uploaded in parts.
+func GuessS3PartSize(etag string, size int64) (int64, error) {
+	_, parts, err := ParseS3ETag(etag)
+	if err != nil {
+		return 0, err
+	}
+	if parts == 0 {
+		return 0, nil
+	}
+	n := int64(parts)
+
+	for _, ps := range commonS3PartSizes {
+		if s3Parts(size, ps) == n {
+			return ps, nil
+		}
+	}
+
+	const MiB = 1024 * 1024
+	ps := (size + n - 1) / n
+	if mb := (ps + MiB - 1) / MiB * MiB; mb > 0 && s3Parts(size, mb) == n {
+		return mb, nil
+	}
+	if ps > 0 && s3Parts(size, ps) == n {
+		return ps, nil
+	}
+
+	return 0, fmt.Errorf("unable to guess part size of %d bytes in %d parts", size, parts)
+}
+
+// ReaderS3ETag hashes everything read from r into the ETag of an S3 object
+// uploaded in parts of partSize bytes, in addition to any other algorithms.
+func ReaderS3ETag(r io.Reader, partSize int64, algs ...Algorithm) (rh *ReaderHashed) {
+	hs := newHashesWith(S3ETag, NewS3ETag(partSize), algs)
+
+	return newReaderHashed(r, hs)
+}
+
+func WriterS3ETag(w io.Writer, partSize int64, algs ...Algorithm) (wh *WriterHashed) {
+	hs := newHashesWith(S3ETag, NewS3ETag(partSize), algs)
+
+	return newWriterHashed(w, hs)
+}
+
+// ETag returns the unquoted S3 ETag when hashing with S3ETag, otherwise "".
+func (hs *hashes) ETag() string {
+	for _, h := range hs.hs {
+		if e, ok := h.(*S3ETagHash); ok {
+			return e.ETag()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package hashed
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io/fs"
+)
+
+var stateMagic = [...]byte { 'h', 'a', 's', 'h', 'e', 'd', 0x01 }
+
+func appendBytes(b []byte, bs []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(bs)))
+	return append(b, bs...)
+}
+
+func readBytes(b []byte) (bs []byte, rest []byte, err error) {
+	if len(b) < 4 {
+		return nil, nil, fmt.Errorf("truncated hash state")
+	}
+	l := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint64(len(b)) < uint64(l) {
+		return nil, nil, fmt.Errorf("truncated hash state")
+	}
+	return b[:l], b[l:], nil
+}
+
+func marshalHash(alg Algorithm, h hash.Hash) ([]byte, error) {
+	m, ok := h.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("hash state of %s can not be marshaled", alg)
+	}
+	return m.MarshalBinary()
+}
+
+func unmarshalHash(alg Algorithm, h hash.Hash, data []byte) error {
+	u, ok := h.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("hash state of %s can not be unmarshaled", alg)
+	}
+	return u.UnmarshalBinary(data)
+}
+
+// MarshalBinary snapshots the running state of the hashes along with the
+// number of bytes hashed, so that hashing can be resumed (e.g. after a
+// process restart) by UnmarshalBinary into a reader or writer hashing with
+// the same algorithms.
+func (hs *hashes) MarshalBinary() ([]byte, error) {
+	b := append([]byte(nil), stateMagic[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(hs.n))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(hs.hs)))
+
+	for i, h := range hs.hs {
+		st, err := marshalHash(hs.algs[i], h)
+		if err != nil {
+			return nil, err
+		}
+		b = appendBytes(b, []byte(hs.algs[i]))
+		b = appendBytes(b, st)
+	}
+
+	return b, nil
+}
+
+func (hs *hashes) UnmarshalBinary(data []byte) error {
+	if hs.frozen != nil {
+		return fs.ErrClosed
+	}
+
+	if !bytes.HasPrefix(data, stateMagic[:]) {
+		return fmt.Errorf("unexpected hash state magic")
+	}
+	b := data[len(stateMagic):]
+
+	if len(b) < 10 {
+		return fmt.Errorf("truncated hash state")
+	}
+	n := int64(binary.BigEndian.Uint64(b))
+	k := int(binary.BigEndian.Uint16(b[8:]))
+	b = b[10:]
+
+	if k != len(hs.hs) {
+		return fmt.Errorf("unexpected number of hashes in state: %d != %d", k, len(hs.hs))
+	}
+
+	sts := make([][]byte, k)
+	for i := range sts {
+		alg, rest, err := readBytes(b)
+		if err != nil {
+			return err
+		}
+		if Algorithm(alg) != hs.algs[i] {
+			return fmt.Errorf("unexpected algorithm in state: %s != %s", alg, hs.algs[i])
+		}
+
+		if sts[i], b, err = readBytes(rest); err != nil {
+			return err
+		}
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("trailing data in hash state")
+	}
+
+	for i, h := range hs.hs {
+		if err := unmarshalHash(hs.algs[i], h, sts[i]); err != nil {
+			return err
+		}
+	}
+	hs.n = n
+
+	return nil
+}
+
+func (h *S3ETagHash) MarshalBinary() ([]byte, error) {
+	st, err := marshalHash(MD5, h.part)
+	if err != nil {
+		return nil, err
+	}
+
+	b := binary.BigEndian.AppendUint64(nil, uint64(h.partSize))
+	b = binary.BigEndian.AppendUint64(b, uint64(h.n))
+	b = binary.BigEndian.AppendUint64(b, uint64(h.count))
+	b = appendBytes(b, h.digests)
+	return appendBytes(b, st), nil
+}
+
+func (h *S3ETagHash) UnmarshalBinary(data []byte) error {
+	if len(data) < 24 {
+		return fmt.Errorf("truncated S3 ETag state")
+	}
+	partSize := int64(binary.BigEndian.Uint64(data))
+	if partSize != h.partSize {
+		return fmt.Errorf("unexpected part size in state: %d != %d", partSize, h.partSize)
+	}
+	n := int64(binary.BigEndian.Uint64(data[8:]))
+	count := int(binary.BigEndian.Uint64(data[16:]))
+
+	digests, rest, err := readBytes(data[24:])
+	if err != nil {
+		return err
+	}
+	st, rest, err := readBytes(rest)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("trailing data in S3 ETag state")
+	}
+
+	if err := unmarshalHash(MD5, h.part, st); err != nil {
+		return err
+	}
+	h.n = n
+	h.count = count
+	h.digests = bytes.Clone(digests)
+	return nil
+}
@@ -0,0 +1,127 @@
+package hashed
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// ParseDigest decodes an expected digest given either as raw bytes, hex,
+// base64 or in the Subresource Integrity form (e.g. "sha256-<base64>"), in
+// which case the algorithm is taken from the prefix.
+func ParseDigest(alg Algorithm, s string) (Algorithm, []byte, error) {
+	if a, b64, ok := strings.Cut(s, "-"); ok {
+		if sri, err := ParseAlgorithm(a); err == nil {
+			bs, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid SRI digest: %s", s)
+			}
+			if len(bs) != sri.Size() {
+				return "", nil, fmt.Errorf("unexpected %s digest length: %d", sri, len(bs))
+			}
+			return sri, bs, nil
+		}
+	}
+
+	if alg == "" {
+		return "", nil, fmt.Errorf("unable to determine digest algorithm: %s", s)
+	}
+	size := alg.Size()
+
+	if len(s) == 2*size {
+		if bs, err := hex.DecodeString(s); err == nil {
+			return alg, bs, nil
+		}
+	}
+
+	for _, enc := range []*base64.Encoding {
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if bs, err := enc.DecodeString(s); err == nil && len(bs) == size {
+			return alg, bs, nil
+		}
+	}
+
+	if len(s) == size {
+		return alg, []byte(s), nil
+	}
+
+	return "", nil, fmt.Errorf("unable to decode %s digest: %q", alg, s)
+}
+
+// VerifyingReader returns ErrDigestMismatch instead of io.EOF when the
+// content read does not match the expected digest.
+type VerifyingReader struct {
+	*ReaderHashed
+	expected []byte
+	err error
+}
+
+func NewVerifyingReader(r io.Reader, alg Algorithm, expected string) (*VerifyingReader, error) {
+	alg, digest, err := ParseDigest(alg, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyingReader {
+		ReaderHashed: Reader(r, alg),
+		expected: digest,
+	}, nil
+}
+
+// NewVerifyingReaderDigest is NewVerifyingReader with the expected digest
+// given as raw bytes.
+func NewVerifyingReaderDigest(r io.Reader, alg Algorithm, expected []byte) (*VerifyingReader, error) {
+	if len(expected) != alg.Size() {
+		return nil, fmt.Errorf("unexpected %s digest length: %d", alg, len(expected))
+	}
+
+	return &VerifyingReader {
+		ReaderHashed: Reader(r, alg),
+		expected: expected,
+	}, nil
+}
+
+func (vr *VerifyingReader) Read(p []byte) (n int, err error) {
+	if vr.err != nil {
+		return 0, vr.err
+	}
+
+	n, err = vr.ReaderHashed.Read(p)
+	if err == io.EOF {
+		err = vr.verify(err)
+	}
+	return
+}
+
+// WriteTo verifies the digest once the content has been written to w, since
+// it reads until EOF.
+func (vr *VerifyingReader) WriteTo(w io.Writer) (n int64, err error) {
+	if vr.err != nil {
+		return 0, vr.err
+	}
+
+	n, err = vr.ReaderHashed.WriteTo(w)
+	if err == nil {
+		err = vr.verify(nil)
+	}
+	return
+}
+
+func (vr *VerifyingReader) verify(err error) error {
+	if actual := vr.Digest(); !Equal(actual, vr.expected) {
+		err = fmt.Errorf("%w: %x != %x", ErrDigestMismatch, actual, vr.expected)
+	}
+	if err == nil {
+		vr.err = io.EOF
+	} else {
+		vr.err = err
+	}
+	return err
+}
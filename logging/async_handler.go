@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+type AsyncPolicy int
+
+const (
+	// AsyncBlock blocks the logging goroutine while the queue is full
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDrop drops records while the queue is full
+	AsyncDrop
+)
+
+type asyncRecord struct {
+	ctx context.Context
+	h slog.Handler
+	r slog.Record
+
+	// flushed, if set, marks a flush and is closed when reached
+	flushed chan struct{}
+}
+
+type asyncQueue struct {
+	ch chan asyncRecord
+	policy AsyncPolicy
+	dropped atomic.Uint64
+
+	mu sync.RWMutex
+	closed bool
+	done chan struct{}
+}
+
+// AsyncHandler hands records to the wrapped handler from a separate goroutine
+// through a bounded queue. Records handled after Close are handed over
+// synchronously.
+type AsyncHandler struct {
+	inner slog.Handler
+	q *asyncQueue
+}
+
+func NewAsyncHandler(inner slog.Handler, size int, policy AsyncPolicy) *AsyncHandler {
+	q := &asyncQueue {
+		ch: make(chan asyncRecord, size),
+		policy: policy,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(q.done)
+		for ar := range q.ch {
+			if ar.flushed != nil {
+				close(ar.flushed)
+				continue
+			}
+			_ = ar.h.Handle(ar.ctx, ar.r)
+		}
+	}()
+
+	return &AsyncHandler { inner: inner, q: q }
+}
+
+func (h *AsyncHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.inner.Enabled(ctx, lvl)
+}
+
+func (h *AsyncHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.q.mu.RLock()
+	defer h.q.mu.RUnlock()
+
+	if h.q.closed {
+		return h.inner.Handle(ctx, r)
+	}
+
+	ar := asyncRecord { ctx: ctx, h: h.inner, r: r.Clone() }
+	if h.q.policy == AsyncDrop {
+		select {
+		case h.q.ch <- ar:
+		default:
+			h.q.dropped.Add(1)
+		}
+	} else {
+		h.q.ch <- ar
+	}
+
+	return nil
+}
+
+func (h *AsyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &AsyncHandler { inner: h.inner.WithAttrs(attrs), q: h.q }
+}
+
+func (h *AsyncHandler) WithGroup(name string) slog.Handler {
+	return &AsyncHandler { inner: h.inner.WithGroup(name), q: h.q }
+}
+
+// Dropped returns the number of records dropped since the queue was full.
+func (h *AsyncHandler) Dropped() uint64 {
+	return h.q.dropped.Load()
+}
+
+// Flush waits until the records queued before it have been handled.
+func (h *AsyncHandler) Flush() {
+	h.q.mu.RLock()
+	if h.q.closed {
+		h.q.mu.RUnlock()
+		return
+	}
+
+	flushed := make(chan struct{})
+	h.q.ch <- asyncRecord { flushed: flushed }
+	h.q.mu.RUnlock()
+
+	<-flushed
+}
+
+// Close waits until the queued records have been handled.
+func (h *AsyncHandler) Close() error {
+	h.q.mu.Lock()
+	if !h.q.closed {
+		h.q.closed = true
+		close(h.q.ch)
+	}
+	h.q.mu.Unlock()
+
+	<-h.q.done
+	return nil
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type blockingWriter struct {
+	sync.Mutex
+	sb strings.Builder
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	return w.sb.Write(p)
+}
+
+func TestAsyncHandler(t *testing.T) {
+	var w blockingWriter
+	cfg := Config {
+		HumanWriter: &w,
+		HumanFields: HumanHandlerFields { OmitTime: true, OmitPID: true, OmitCaller: true },
+		AsyncQueueSize: 2,
+	}
+
+	logger, closer, err := cfg.SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		logger.With("i", i).Info("hello")
+	}
+	if err := closer(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after close")
+
+	expected := "INFO hello (i: 0)\nINFO hello (i: 1)\nINFO hello (i: 2)\nINFO hello (i: 3)\nINFO hello (i: 4)\nINFO after close\n"
+	if w.sb.String() != expected {
+		t.Errorf("unexpected output: %q", w.sb.String())
+	}
+}
+
+func TestAsyncHandlerDrop(t *testing.T) {
+	var w blockingWriter
+	ah := NewAsyncHandler(NewHumanHandler(&w, LevelInfo, HumanHandlerFields { OmitTime: true }), 1, AsyncDrop)
+
+	// hold up the handling goroutine
+	w.Lock()
+	for i := 0; i < 10; i++ {
+		_ = ah.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "hello", 0))
+	}
+	w.Unlock()
+	ah.Flush()
+
+	n := strings.Count(w.sb.String(), "\n")
+	if n < 1 || n > 2 || uint64(n) + ah.Dropped() != 10 {
+		t.Errorf("unexpected number of handled and dropped records: %d %d", n, ah.Dropped())
+	}
+
+	if err := ah.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
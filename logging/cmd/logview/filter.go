@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"rootmos.io/go-utils/logging"
+)
+
+type filter func(r *slog.Record) bool
+
+type filters []filter
+
+func (fs filters) match(r *slog.Record) bool {
+	for _, f := range fs {
+		if !f(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup finds the attribute with the dotted key, e.g. caller.name
+func lookup(r *slog.Record, key string) (v slog.Value, found bool) {
+	path := strings.Split(key, ".")
+
+	var f func(as []slog.Attr, path []string)
+	f = func(as []slog.Attr, path []string) {
+		for _, a := range as {
+			if a.Key != path[0] {
+				continue
+			}
+			if len(path) == 1 {
+				v, found = a.Value, true
+				return
+			}
+			if a.Value.Kind() == slog.KindGroup {
+				f(a.Value.Group(), path[1:])
+				return
+			}
+		}
+	}
+
+	var as []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		as = append(as, a)
+		return true
+	})
+	f(as, path)
+	return
+}
+
+func levelFilter(s string) (filter, error) {
+	l, err := logging.ParseLevel(s)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *slog.Record) bool {
+		return r.Level >= slog.Level(l)
+	}, nil
+}
+
+// parseTime parses either an RFC3339 time or a duration before now
+func parseTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func sinceFilter(s string) (filter, error) {
+	t, err := parseTime(s)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *slog.Record) bool {
+		return !r.Time.Before(t)
+	}, nil
+}
+
+func untilFilter(s string) (filter, error) {
+	t, err := parseTime(s)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *slog.Record) bool {
+		return r.Time.Before(t)
+	}, nil
+}
+
+func pidFilter(pid int64) filter {
+	return func(r *slog.Record) bool {
+		v, ok := lookup(r, "pid")
+		return ok && v.Kind() == slog.KindInt64 && v.Int64() == pid
+	}
+}
+
+func callerFilter(s string) (filter, error) {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *slog.Record) bool {
+		v, ok := lookup(r, "caller.name")
+		return ok && re.MatchString(v.String())
+	}, nil
+}
+
+var exprRegexp = regexp.MustCompile(`^([^=!~<>]+)(?:(=|!=|~|!~|<=|>=|<|>)(.*))?$`)
+
+// whereFilter parses an attribute expression: key (present), key=value,
+// key!=value, key~regexp, key!~regexp or a numeric comparison key<n, key<=n,
+// key>n and key>=n.
+func whereFilter(expr string) (filter, error) {
+	m := exprRegexp.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid expression: %q", expr)
+	}
+	key, op, operand := m[1], m[2], m[3]
+
+	switch op {
+	case "":
+		return func(r *slog.Record) bool {
+			_, ok := lookup(r, key)
+			return ok
+		}, nil
+	case "=", "!=":
+		return func(r *slog.Record) bool {
+			v, ok := lookup(r, key)
+			return (ok && v.String() == operand) == (op == "=")
+		}, nil
+	case "~", "!~":
+		re, err := regexp.Compile(operand)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *slog.Record) bool {
+			v, ok := lookup(r, key)
+			return (ok && re.MatchString(v.String())) == (op == "~")
+		}, nil
+	default:
+		x, err := strconv.ParseFloat(operand, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number in expression: %q", expr)
+		}
+		return func(r *slog.Record) bool {
+			v, ok := lookup(r, key)
+			if !ok {
+				return false
+			}
+
+			var y float64
+			switch v.Kind() {
+			case slog.KindInt64:
+				y = float64(v.Int64())
+			case slog.KindFloat64:
+				y = v.Float64()
+			default:
+				return false
+			}
+
+			switch op {
+			case "<":
+				return y < x
+			case "<=":
+				return y <= x
+			case ">":
+				return y > x
+			default:
+				return y >= x
+			}
+		}, nil
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func record(level slog.Level, t time.Time, attrs ...any) *slog.Record {
+	r := slog.NewRecord(t, level, "msg", 0)
+	r.Add(attrs...)
+	return &r
+}
+
+func TestFilters(t *testing.T) {
+	now := time.Now()
+	r := record(slog.LevelWarn, now.Add(-time.Hour),
+		"pid", 7,
+		slog.Group("caller", "name", "rootmos.io/go-utils/osext.Create"),
+		"n", 3,
+		"f", 1.5,
+		"s", "hello world",
+		slog.Group("req", "id", "abc"),
+	)
+
+	must := func(f filter, err error) filter {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	for name, c := range map[string]struct {
+		f filter
+		expected bool
+	} {
+		"level below": { must(levelFilter("INFO")), true },
+		"level equal": { must(levelFilter("warn")), true },
+		"level above": { must(levelFilter("ERROR")), false },
+		"since before": { must(sinceFilter("2h")), true },
+		"since after": { must(sinceFilter("30m")), false },
+		"since RFC3339": { must(sinceFilter(now.Add(-2 * time.Hour).Format(time.RFC3339))), true },
+		"until after": { must(untilFilter("30m")), true },
+		"until before": { must(untilFilter("2h")), false },
+		"pid": { pidFilter(7), true },
+		"other pid": { pidFilter(8), false },
+		"caller": { must(callerFilter(`osext\.Create$`)), true },
+		"other caller": { must(callerFilter(`^main\.`)), false },
+	} {
+		if c.f(r) != c.expected {
+			t.Errorf("%s: unexpected match: %t", name, !c.expected)
+		}
+	}
+
+	if _, err := levelFilter("LOUD"); err == nil {
+		t.Errorf("unexpected success parsing invalid level")
+	}
+	if _, err := sinceFilter("yesterday"); err == nil {
+		t.Errorf("unexpected success parsing invalid time")
+	}
+	if _, err := callerFilter("("); err == nil {
+		t.Errorf("unexpected success parsing invalid regexp")
+	}
+}
+
+func TestWhereFilter(t *testing.T) {
+	r := record(slog.LevelInfo, time.Now(),
+		"n", 3,
+		"f", 1.5,
+		"s", "hello world",
+		slog.Group("req", "id", "abc"),
+	)
+
+	for expr, expected := range map[string]bool {
+		"n": true,
+		"missing": false,
+		"req.id": true,
+		"req.missing": false,
+		"req.id=abc": true,
+		"req.id=abd": false,
+		"req.id!=abd": true,
+		"missing!=abd": true,
+		"s~^hello": true,
+		"s~^world": false,
+		"s!~^world": true,
+		"n<4": true,
+		"n<3": false,
+		"n<=3": true,
+		"n>2": true,
+		"n>=3.5": false,
+		"f>1": true,
+		"s>1": false,
+		"missing<1": false,
+		"s=hello world": true,
+	} {
+		f, err := whereFilter(expr)
+		if err != nil {
+			t.Errorf("unable to parse %q: %v", expr, err)
+			continue
+		}
+		if f(r) != expected {
+			t.Errorf("%q: unexpected match: %t", expr, !expected)
+		}
+	}
+
+	for _, expr := range []string { "", "=abc", "~x", "n<", "n<three", "n>=1e", "s~(", "s!~[" } {
+		if _, err := whereFilter(expr); err == nil {
+			t.Errorf("unexpected success parsing %q", expr)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// follower reads a file being appended to, and when at its end reopens it if
+// rotated (replaced by another file) or reads it from the start if truncated
+// to less than has been read
+type follower struct {
+	name string
+	f *os.File
+	offset int64
+}
+
+func openFollower(name string) (*follower, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &follower { name: name, f: f }, nil
+}
+
+func (fl *follower) Read(p []byte) (n int, err error) {
+	n, err = fl.f.Read(p)
+	fl.offset += int64(n)
+	if n > 0 || !errors.Is(err, io.EOF) {
+		return
+	}
+
+	cur, err := fl.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := os.Stat(fl.name)
+	switch {
+	case err != nil:
+		// moved away and not yet recreated
+		return 0, io.EOF
+	case !os.SameFile(fi, cur):
+		f, err := os.Open(fl.name)
+		if err != nil {
+			return 0, io.EOF
+		}
+		fl.f.Close()
+		fl.f = f
+	case cur.Size() < fl.offset:
+		if _, err := fl.f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, io.EOF
+	}
+
+	fl.offset = 0
+	n, err = fl.f.Read(p)
+	fl.offset += int64(n)
+	return
+}
+
+func (fl *follower) Close() error {
+	return fl.f.Close()
+}
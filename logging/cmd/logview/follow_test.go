@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(path, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fl, err := openFollower(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+	br := bufio.NewReader(fl)
+
+	expect := func(expected string) {
+		t.Helper()
+		l, err := br.ReadString('\n')
+		if err != nil || l != expected {
+			t.Fatalf("unexpected line: %q != %q (%v)", l, expected, err)
+		}
+		if _, err := br.ReadString('\n'); err == nil {
+			t.Fatalf("unexpected line after %q", expected)
+		}
+	}
+	appendLine := func(l string) {
+		t.Helper()
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect("first\n")
+
+	appendLine("appended\n")
+	expect("appended\n")
+
+	// rotated: moved away, and a new file created
+	if err := os.Rename(path, path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := br.ReadString('\n'); err == nil {
+		t.Fatal("unexpected line before recreated")
+	}
+	if err := os.WriteFile(path, []byte("rotated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("rotated\n")
+
+	// truncated, e.g. by logrotate's copytruncate, which is noticed when
+	// shorter than what has been read
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendLine("new\n")
+	expect("new\n")
+}
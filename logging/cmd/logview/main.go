@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"rootmos.io/go-utils/logging"
+)
+
+const EnvPrefix = "LOGVIEW_"
+
+const (
+	ExitFailure = 1
+	ExitUsage = 2
+)
+
+type expressions []string
+
+func (es *expressions) String() string {
+	return strings.Join(*es, ",")
+}
+
+func (es *expressions) Set(s string) error {
+	*es = append(*es, s)
+	return nil
+}
+
+func parseColorMode(s string) (logging.ColorMode, error) {
+	switch strings.ToLower(s) {
+	case "auto", "":
+		return logging.ColorAuto, nil
+	case "never", "no", "false":
+		return logging.ColorNever, nil
+	case "always", "yes", "true":
+		return logging.ColorAlways, nil
+	default:
+		return 0, fmt.Errorf("invalid color mode: %s", s)
+	}
+}
+
+type viewer struct {
+	h *logging.HumanHandler
+	fs filters
+
+	logger *logging.Logger
+}
+
+func (v *viewer) line(name string, n int, bs []byte) error {
+	if len(strings.TrimSpace(string(bs))) == 0 {
+		return nil
+	}
+
+	r, err := logging.ParseJSONRecord(bs)
+	if err != nil {
+		v.logger.Warn("ignoring malformed line", "file", name, "line", n, "err", err)
+		return nil
+	}
+
+	if !v.fs.match(&r) {
+		return nil
+	}
+
+	return v.h.Handle(context.Background(), r)
+}
+
+// read reads the lines of r, and when following waits for more to be
+// appended instead of stopping at EOF (see follower for rotated files)
+func (v *viewer) read(name string, r io.Reader, follow bool) error {
+	br := bufio.NewReader(r)
+	var partial []byte
+	for n := 1; ; {
+		bs, err := br.ReadBytes('\n')
+		partial = append(partial, bs...)
+
+		if errors.Is(err, io.EOF) {
+			if follow {
+				time.Sleep(200 * time.Millisecond)
+				continue
+			}
+			return v.line(name, n, partial)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := v.line(name, n, partial); err != nil {
+			return err
+		}
+		partial = partial[:0]
+		n += 1
+	}
+}
+
+func main() {
+	level := flag.String("level", "TRACE", "show records of at least level")
+	since := flag.String("since", "", "show records since time (RFC3339 or a duration ago, e.g. 1h)")
+	until := flag.String("until", "", "show records before time (RFC3339 or a duration ago)")
+	pid := flag.Int64("pid", 0, "show records of the process")
+	caller := flag.String("caller", "", "show records of callers matching regexp")
+	var wheres expressions
+	flag.Var(&wheres, "where", "show records whose attributes match expression (key, key=value, key!=value, key~regexp, key!~regexp, key<n, key>n, may be repeated)")
+	follow := flag.Bool("f", false, "wait for records appended to the file")
+
+	var fields logging.HumanHandlerFields
+	flag.BoolVar(&fields.OmitTime, "omit-time", false, "omit time")
+	flag.BoolVar(&fields.OmitPID, "omit-pid", false, "omit pid")
+	flag.BoolVar(&fields.OmitCaller, "omit-caller", false, "omit caller")
+	flag.BoolVar(&fields.OmitLevel, "omit-level", false, "omit level")
+	color := flag.String("color", "auto", "color output (auto, always or never)")
+	timeLayout := flag.String("time-layout", "", "format time using layout (default " + logging.CompactRFC3339Layout + ")")
+
+	logConfig := logging.PrepareConfig(EnvPrefix)
+	flag.Parse()
+
+	logger, closer, err := logConfig.SetupDefaultLogger()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closer()
+
+	var fs filters
+	add := func(f filter, err error) {
+		if err != nil {
+			logger.Exitf(ExitUsage, "%s", err)
+		}
+		fs = append(fs, f)
+	}
+	add(levelFilter(*level))
+	if *since != "" {
+		add(sinceFilter(*since))
+	}
+	if *until != "" {
+		add(untilFilter(*until))
+	}
+	if *pid != 0 {
+		add(pidFilter(*pid), nil)
+	}
+	if *caller != "" {
+		add(callerFilter(*caller))
+	}
+	for _, w := range wheres {
+		add(whereFilter(w))
+	}
+
+	if fields.Color, err = parseColorMode(*color); err != nil {
+		logger.Exitf(ExitUsage, "%s", err)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string { "-" }
+	}
+	if *follow && len(files) != 1 {
+		logger.Exitf(ExitUsage, "-f expects a single file (%d given)", len(files))
+	}
+
+	h := logging.NewHumanHandler(os.Stdout, logging.LevelTrace, fields)
+	h.TimeLayout = *timeLayout
+
+	v := viewer {
+		h: h,
+		fs: fs,
+		logger: logger,
+	}
+
+	for _, f := range files {
+		var r io.ReadCloser
+		switch {
+		case f == "-":
+			r = os.Stdin
+		case *follow:
+			r, err = openFollower(f)
+		default:
+			r, err = os.Open(f)
+		}
+		if err != nil {
+			logger.Exitf(ExitFailure, "unable to open: %s", err)
+		}
+
+		err := v.read(f, r, *follow && f != "-")
+		r.Close()
+		if err != nil {
+			logger.Exitf(ExitFailure, "unable to read %s: %s", f, err)
+		}
+	}
+}
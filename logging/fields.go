@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+)
+
+// field is an attribute flattened to a key of its groups joined by dots,
+// e.g. caller.line
+type field struct {
+	key string
+	value slog.Value
+}
+
+func flatten(fs []field, prefix string, a slog.Attr) []field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fs
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, b := range a.Value.Group() {
+			fs = flatten(fs, p, b)
+		}
+		return fs
+	}
+
+	return append(fs, field { key: prefix + a.Key, value: a.Value })
+}
+
+func fieldString(v slog.Value) string {
+	if v.Kind() == slog.KindTime {
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	return v.String()
+}
+
+type callerInfo struct {
+	name string
+	file string
+	line int64
+}
+
+// flatFields are the fields added by WithAttrs to the handlers flattening the
+// attributes, and the prefix of the groups opened by WithGroup.
+type flatFields struct {
+	prefix string
+	fs []field
+}
+
+func (ff flatFields) withAttrs(attrs []slog.Attr) flatFields {
+	fs := ff.fs[:len(ff.fs):len(ff.fs)]
+	for _, a := range attrs {
+		fs = flatten(fs, ff.prefix, a)
+	}
+	return flatFields { prefix: ff.prefix, fs: fs }
+}
+
+func (ff flatFields) withGroup(name string) flatFields {
+	if name == "" {
+		return ff
+	}
+	return flatFields { prefix: ff.prefix + name + ".", fs: ff.fs }
+}
+
+// record returns the fields of the record, except the pid and caller added by
+// Logger which are returned separately (or -1 when missing), as HumanHandler
+// does.
+func (ff flatFields) record(r slog.Record) (pid int64, caller callerInfo, fs []field) {
+	pid, caller.line = -1, -1
+	fs = ff.fs[:len(ff.fs):len(ff.fs)]
+
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "pid" && a.Value.Kind() == slog.KindInt64 {
+			pid = a.Value.Int64()
+			return true
+		}
+
+		if a.Key == "caller" && a.Value.Kind() == slog.KindGroup {
+			for _, b := range a.Value.Group() {
+				switch {
+				case b.Key == "name" && b.Value.Kind() == slog.KindString:
+					caller.name = b.Value.String()
+				case b.Key == "file" && b.Value.Kind() == slog.KindString:
+					caller.file = b.Value.String()
+				case b.Key == "line" && b.Value.Kind() == slog.KindInt64:
+					caller.line = b.Value.Int64()
+				}
+			}
+			return true
+		}
+
+		fs = flatten(fs, ff.prefix, a)
+		return true
+	})
+
+	return
+}
@@ -1,3 +1,7 @@
 module rootmos.io/go-utils/logging
 
 go 1.21.5
+
+require golang.org/x/term v0.18.0
+
+require golang.org/x/sys v0.18.0 // indirect
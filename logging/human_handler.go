@@ -1,22 +1,28 @@
 package logging
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 type HumanHandler struct {
 	w io.Writer
 
-	Level Level
+	// Level is e.g. a Level or a LevelVar
+	Level slog.Leveler
 	Fields HumanHandlerFields
 	TimeLayout string
 
+	color bool
 	groups []group
 }
 
@@ -30,10 +36,96 @@ type HumanHandlerFields struct {
 	OmitPID bool
 	OmitCaller bool
 	OmitLevel bool
+
+	Color ColorMode
+}
+
+type ColorMode int
+
+const (
+	// ColorAuto colors the output when writing to a terminal, unless
+	// overridden by the NO_COLOR or FORCE_COLOR environment variables
+	ColorAuto ColorMode = iota
+	ColorNever
+	ColorAlways
+)
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+func (m ColorMode) enabled(w io.Writer) bool {
+	switch m {
+	case ColorNever:
+		return false
+	case ColorAlways:
+		return true
+	}
+
+	if v := os.Getenv("NO_COLOR"); v != "" {
+		return false
+	}
+	if v := os.Getenv("FORCE_COLOR"); v != "" && v != "0" && v != "false" {
+		return true
+	}
+	return isTerminal(w)
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold = "\x1b[1m"
+	ansiDim = "\x1b[2m"
+	ansiRed = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan = "\x1b[36m"
+)
+
+func paint(color bool, code, s string) string {
+	if !color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+func levelColor(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return ansiBold + ansiRed
+	case l >= slog.LevelWarn:
+		return ansiYellow
+	case l >= slog.LevelInfo:
+		return ansiGreen
+	case l >= slog.LevelDebug:
+		return ansiCyan
+	default:
+		return ansiMagenta
+	}
+}
+
+// NewHumanHandler returns a handler writing to w, coloring the output
+// according to fields.Color.
+func NewHumanHandler(w io.Writer, level slog.Leveler, fields HumanHandlerFields) *HumanHandler {
+	return &HumanHandler {
+		w: w,
+		Level: level,
+		Fields: fields,
+		color: fields.Color.enabled(w),
+	}
 }
 
 func (h *HumanHandler) Enabled(_ context.Context, lvl slog.Level) bool {
-	return h.w != nil && lvl >= slog.Level(h.Level)
+	min := slog.LevelInfo
+	if h.Level != nil {
+		min = h.Level.Level()
+	}
+	return h.w != nil && lvl >= min
 }
 
 const CompactRFC3339Layout = "20060102T150405Z"
@@ -56,11 +148,12 @@ func maybeRelPath(path string) string {
 	}
 }
 
-func renderAttr(a *slog.Attr) string {
+func renderAttr(a *slog.Attr, color bool) string {
+	key := paint(color, ansiBlue, a.Key)
 	if a.Value.Kind() == slog.KindGroup {
 		var sb strings.Builder
 
-		if _, err := fmt.Fprintf(&sb, "(%s", a.Key); err != nil {
+		if _, err := fmt.Fprintf(&sb, "(%s", key); err != nil {
 			panic(err)
 		}
 
@@ -75,7 +168,7 @@ func renderAttr(a *slog.Attr) string {
 				}
 			}
 
-			if _, err := sb.WriteString(renderAttr(&a)); err != nil {
+			if _, err := sb.WriteString(renderAttr(&a, color)); err != nil {
 				panic(err)
 			}
 		}
@@ -86,7 +179,7 @@ func renderAttr(a *slog.Attr) string {
 
 		return sb.String()
 	} else {
-		return fmt.Sprintf("(%s: %v)", a.Key, a.Value)
+		return fmt.Sprintf("(%s: %v)", key, a.Value)
 	}
 }
 
@@ -119,20 +212,24 @@ func (h0 *HumanHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 
 	g := h1.currentGroup()
 	for _, a := range attrs {
-		g.attrs = append(g.attrs, renderAttr(&a))
+		g.attrs = append(g.attrs, renderAttr(&a, h1.color))
 	}
 
 	return &h1
 }
 
 func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
+	// render the record and write it at once, so that lines are neither
+	// interleaved nor split by a rotation
+	var b bytes.Buffer
+
 	var fieldPrefix string
 	if !h.Fields.OmitTime {
 		layout := h.TimeLayout
 		if layout == "" {
 			layout = CompactRFC3339Layout
 		}
-		if _, err = io.WriteString(h.w, r.Time.UTC().Format(layout)); err != nil {
+		if _, err = io.WriteString(&b, paint(h.color, ansiDim, r.Time.UTC().Format(layout))); err != nil {
 			return err
 		}
 		fieldPrefix = ":"
@@ -173,7 +270,7 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 	})
 
 	if !h.Fields.OmitPID && pid >= 0 {
-		if _, err = fmt.Fprintf(h.w, "%s%d", fieldPrefix, pid); err != nil {
+		if _, err = fmt.Fprintf(&b, "%s%s", fieldPrefix, paint(h.color, ansiDim, strconv.FormatInt(pid, 10))); err != nil {
 			return err
 		}
 		fieldPrefix = ":"
@@ -181,7 +278,7 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 
 	if !h.Fields.OmitCaller {
 		if caller != "" {
-			if _, err = fmt.Fprintf(h.w, "%s%s", fieldPrefix, caller); err != nil {
+			if _, err = fmt.Fprintf(&b, "%s%s", fieldPrefix, paint(h.color, ansiDim, caller)); err != nil {
 				return err
 			}
 		}
@@ -189,14 +286,14 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 
 		if file != "" {
 			path := maybeRelPath(file)
-			if _, err = fmt.Fprintf(h.w, "%s%s", fieldPrefix, path); err != nil {
+			if _, err = fmt.Fprintf(&b, "%s%s", fieldPrefix, paint(h.color, ansiDim, path)); err != nil {
 				return err
 			}
 		}
 		fieldPrefix = ":"
 
 		if line >= 0 {
-			if _, err = fmt.Fprintf(h.w, "%s%d", fieldPrefix, line); err != nil {
+			if _, err = fmt.Fprintf(&b, "%s%s", fieldPrefix, paint(h.color, ansiDim, strconv.FormatInt(line, 10))); err != nil {
 				return err
 			}
 		}
@@ -208,19 +305,19 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 		if r.Level == slog.Level(LevelTrace) {
 			l = "TRACE"
 		}
-		if _, err = fmt.Fprintf(h.w, "%s%s", fieldPrefix, l); err != nil {
+		if _, err = fmt.Fprintf(&b, "%s%s", fieldPrefix, paint(h.color, levelColor(r.Level), l)); err != nil {
 			return err
 		}
 		fieldPrefix = ":"
 	}
 
 	if fieldPrefix != "" {
-		if _, err = io.WriteString(h.w, " "); err != nil {
+		if _, err = io.WriteString(&b, " "); err != nil {
 			return err
 		}
 	}
 
-	if _, err = io.WriteString(h.w, r.Message); err != nil {
+	if _, err = io.WriteString(&b, r.Message); err != nil {
 		return err
 	}
 
@@ -238,11 +335,11 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 
 		if len(g.attrs) > 0 || len(attrs) > 0 {
 			if i == 0 {
-				if _, err = io.WriteString(h.w, " "); err != nil {
+				if _, err = io.WriteString(&b, " "); err != nil {
 					return err
 				}
 			} else {
-				if _, err = fmt.Fprintf(h.w, "(%s: ", g.name); err != nil {
+				if _, err = fmt.Fprintf(&b, "(%s: ", paint(h.color, ansiBlue, g.name)); err != nil {
 					return err
 				}
 			}
@@ -250,12 +347,12 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 
 		for j, a := range g.attrs {
 			if j > 0 {
-				if _, err = io.WriteString(h.w, " "); err != nil {
+				if _, err = io.WriteString(&b, " "); err != nil {
 					return err
 				}
 			}
 
-			if _, err = io.WriteString(h.w, a); err != nil {
+			if _, err = io.WriteString(&b, a); err != nil {
 				return err
 			}
 		}
@@ -263,11 +360,11 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 		if n == 0 || i == n - 1 {
 			for j, a := range attrs {
 				if j > 0 || len(g.attrs) > 0 {
-					if _, err = io.WriteString(h.w, " "); err != nil {
+					if _, err = io.WriteString(&b, " "); err != nil {
 						return err
 					}
 				}
-				if _, err = io.WriteString(h.w, renderAttr(a)); err != nil {
+				if _, err = io.WriteString(&b, renderAttr(a, h.color)); err != nil {
 					return err
 				}
 			}
@@ -279,7 +376,7 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 		}
 
 		if i != 0 {
-			if _, err = io.WriteString(h.w, ")"); err != nil {
+			if _, err = io.WriteString(&b, ")"); err != nil {
 				return err
 			}
 		}
@@ -291,9 +388,8 @@ func (h *HumanHandler) Handle(_ context.Context, r slog.Record) (err error) {
 		return err
 	}
 
-	if _, err = io.WriteString(h.w, "\n"); err != nil {
-		return err
-	}
+	b.WriteByte('\n')
 
-	return nil
+	_, err = h.w.Write(b.Bytes())
+	return err
 }
@@ -1,9 +1,11 @@
 package logging
 
 import (
+	"bytes"
 	"os"
 	"log"
 	"log/slog"
+	"testing"
 )
 
 func ExampleHumanHandler() {
@@ -34,9 +36,66 @@ func ExampleHumanHandler() {
 	logger.Trace("bye", slog.Group("g", "f", 11))
 
 	// Output:
-	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:25:INFO hello: 7
-	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:26:INFO foo (a: 8)
-	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:29:DEBUG bar (b: 9)
-	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:32:WARN baz (c: (d: true) (e: 10))
-	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:34:TRACE bye (g: (f: 11))
+	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:27:INFO hello: 7
+	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:28:INFO foo (a: 8)
+	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:31:DEBUG bar (b: 9)
+	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:34:WARN baz (c: (d: true) (e: 10))
+	// rootmos.io/go-utils/logging.ExampleHumanHandler:human_handler_test.go:36:TRACE bye (g: (f: 11))
+}
+
+func TestColor(t *testing.T) {
+	render := func(mode ColorMode) string {
+		var buf bytes.Buffer
+		cfg := Config {
+			HumanWriter: &buf,
+			HumanFields: HumanHandlerFields {
+				OmitTime: true,
+				OmitPID: true,
+				OmitCaller: true,
+				Color: mode,
+			},
+		}
+
+		logger, _, err := cfg.SetupLogger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Warn("hello", "a", 1)
+		return buf.String()
+	}
+
+	plain := "WARN hello (a: 1)\n"
+	colored := "\x1b[33mWARN\x1b[0m hello (\x1b[34ma\x1b[0m: 1)\n"
+
+	if s := render(ColorAlways); s != colored {
+		t.Errorf("unexpected output: %q", s)
+	}
+	if s := render(ColorAuto); s != plain {
+		t.Errorf("unexpected output: %q", s)
+	}
+
+	t.Setenv("FORCE_COLOR", "1")
+	if s := render(ColorAuto); s != colored {
+		t.Errorf("unexpected output with FORCE_COLOR: %q", s)
+	}
+	if s := render(ColorNever); s != plain {
+		t.Errorf("unexpected output: %q", s)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if s := render(ColorAuto); s != plain {
+		t.Errorf("unexpected output with NO_COLOR: %q", s)
+	}
+}
+
+func TestDevNullIsNotTerminal(t *testing.T) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if isTerminal(f) {
+		t.Errorf("%s considered a terminal", os.DevNull)
+	}
 }
@@ -0,0 +1,275 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HumanRecord is a line written by HumanHandler parsed back into its parts.
+// Fields omitted from the line are left as their zero values, except PID and
+// Line which are then -1.
+type HumanRecord struct {
+	Time time.Time
+	PID int
+	Caller string
+	File string
+	Line int
+	Level Level
+	Message string
+	Attrs []slog.Attr
+}
+
+// Record returns the record as it was most likely handed to HumanHandler,
+// i.e. with the caller group and pid attributes added by Logger.
+func (hr *HumanRecord) Record() slog.Record {
+	r := slog.NewRecord(hr.Time, slog.Level(hr.Level), hr.Message, 0)
+	r.AddAttrs(hr.Attrs...)
+
+	if hr.Caller != "" || hr.File != "" || hr.Line >= 0 {
+		var as []any
+		if hr.Caller != "" {
+			as = append(as, slog.String("name", hr.Caller))
+		}
+		if hr.File != "" {
+			as = append(as, slog.String("file", hr.File))
+		}
+		if hr.Line >= 0 {
+			as = append(as, slog.Int("line", hr.Line))
+		}
+		r.AddAttrs(slog.Group("caller", as...))
+	}
+
+	if hr.PID >= 0 {
+		r.AddAttrs(slog.Int("pid", hr.PID))
+	}
+
+	return r
+}
+
+// HumanParser parses lines written by a HumanHandler with the same Fields
+// and TimeLayout. The types of the attributes' values are lost when rendered,
+// so they are guessed: integers, floats and booleans are recognized, all else
+// are strings.
+type HumanParser struct {
+	Fields HumanHandlerFields
+	TimeLayout string
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func (p *HumanParser) Parse(line string) (hr *HumanRecord, err error) {
+	line = ansiEscape.ReplaceAllString(line, "")
+	hr = &HumanRecord { PID: -1, Line: -1 }
+
+	f := p.Fields
+	hasHeader := !f.OmitPID || !f.OmitCaller || !f.OmitLevel
+	if !f.OmitTime {
+		if hr.Time, line, err = p.parseTime(line); err != nil {
+			return nil, err
+		}
+
+		// the time is followed by the rest of the header or the message
+		if rest, ok := strings.CutPrefix(line, ":"); ok {
+			line = rest
+		} else if !f.OmitLevel {
+			return nil, fmt.Errorf("truncated header: %q", line)
+		} else {
+			hasHeader = false
+			line = strings.TrimPrefix(line, " ")
+		}
+	}
+
+	if hasHeader {
+		header, rest, ok := strings.Cut(line, " ")
+		if err := p.parseHeader(hr, header); err != nil {
+			return nil, err
+		}
+		if !ok {
+			return hr, nil
+		}
+		line = rest
+	}
+
+	hr.Message, hr.Attrs = parseAttrs(line)
+	return hr, nil
+}
+
+// parseTime parses the time at the start of the line, which depending on the
+// layout may contain colons and spaces, and returns the rest of the line
+func (p *HumanParser) parseTime(line string) (t time.Time, rest string, err error) {
+	layout := p.TimeLayout
+	if layout == "" {
+		layout = CompactRFC3339Layout
+	}
+
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && line[i] != ':' && line[i] != ' ' {
+			continue
+		}
+		if t, err = time.Parse(layout, line[:i]); err == nil {
+			return t, line[i:], nil
+		}
+	}
+	return t, "", fmt.Errorf("no time of layout %q: %q", layout, line)
+}
+
+func (p *HumanParser) parseHeader(hr *HumanRecord, header string) (err error) {
+	ts := strings.Split(header, ":")
+	take := func() (string, error) {
+		if len(ts) < 1 {
+			return "", fmt.Errorf("truncated header: %q", header)
+		}
+		s := ts[0]
+		ts = ts[1:]
+		return s, nil
+	}
+	takeLast := func() (string, error) {
+		if len(ts) < 1 {
+			return "", fmt.Errorf("truncated header: %q", header)
+		}
+		s := ts[len(ts)-1]
+		ts = ts[:len(ts)-1]
+		return s, nil
+	}
+
+	if !p.Fields.OmitLevel {
+		s, err := takeLast()
+		if err != nil {
+			return err
+		}
+		if hr.Level, err = ParseLevel(s); err != nil {
+			return err
+		}
+	}
+
+	// the caller (name:file:line) is omitted when unknown, e.g. in the
+	// summaries of SamplingHandler, leaving at most the pid
+	if !p.Fields.OmitCaller && len(ts) >= 3 {
+		s, _ := takeLast()
+		if hr.Line, err = strconv.Atoi(s); err != nil {
+			return fmt.Errorf("malformed line: %q", s)
+		}
+		hr.File, _ = takeLast()
+		hr.Caller, _ = takeLast()
+	}
+
+	// the pid is omitted when unknown
+	if !p.Fields.OmitPID && len(ts) > 0 {
+		s, _ := take()
+		if hr.PID, err = strconv.Atoi(s); err != nil {
+			return fmt.Errorf("malformed pid: %q", s)
+		}
+	}
+
+	if len(ts) > 0 {
+		return fmt.Errorf("unexpected fields in header: %q", header)
+	}
+
+	return nil
+}
+
+// matching returns the index of the parenthesis opening the one closing s
+func matching(s string) int {
+	depth := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case ')':
+			depth += 1
+		case '(':
+			depth -= 1
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAttrs splits the trailing "(k: v)" items of s
+func splitAttrs(s string) (rest string, items []string) {
+	for strings.HasSuffix(s, ")") {
+		i := matching(s)
+		if i < 0 || (i > 0 && s[i-1] != ' ') {
+			break
+		}
+		if !strings.Contains(s[i:], ": ") {
+			break
+		}
+
+		items = append([]string { s[i:] }, items...)
+		s = strings.TrimSuffix(s[:i], " ")
+	}
+	return s, items
+}
+
+// parseAttr parses an item of splitAttrs, reversing renderAttr
+func parseAttr(item string) slog.Attr {
+	k, v, _ := strings.Cut(item[1:len(item)-1], ": ")
+
+	if strings.HasPrefix(v, "(") {
+		if rest, items := splitAttrs(v); rest == "" {
+			var as []any
+			for _, i := range items {
+				as = append(as, parseAttr(i))
+			}
+			return slog.Group(k, as...)
+		}
+	}
+
+	return slog.Attr { Key: k, Value: guessValue(v) }
+}
+
+func guessValue(s string) slog.Value {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return slog.Int64Value(i)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return slog.Float64Value(f)
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return slog.BoolValue(b)
+	}
+	return slog.StringValue(s)
+}
+
+func parseAttrs(s string) (msg string, attrs []slog.Attr) {
+	msg, items := splitAttrs(s)
+	for _, item := range items {
+		attrs = append(attrs, parseAttr(item))
+	}
+	return msg, attrs
+}
+
+// HumanToJSON converts the lines read from r into JSON lines as written by
+// the JSON handler set up by Config.
+func (p *HumanParser) HumanToJSON(w io.Writer, r io.Reader) error {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions {
+		Level: slog.Level(LevelTrace),
+		ReplaceAttr: jsonReplaceAttr,
+	})
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		if s.Text() == "" {
+			continue
+		}
+
+		hr, err := p.Parse(s.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+
+		if err := h.Handle(context.Background(), hr.Record()); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
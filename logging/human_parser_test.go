@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanParserRoundtrip(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config {
+		HumanWriter: &buf,
+		HumanLevel: LevelTrace,
+	}
+
+	logger, _, err := cfg.SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, line, _ := runtime.Caller(0)
+	logger.WithGroup("c").Warn("hello (world)", "a", 1, "b", "two words", slog.Group("g", "f", 1.5, "t", true))
+	logger.Trace("bye")
+
+	p := HumanParser {}
+	ls := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	hr, err := p.Parse(ls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hr.Message != "hello (world)" || hr.Level != LevelWarn {
+		t.Errorf("unexpected message or level: %q %v", hr.Message, hr.Level)
+	}
+	if time.Since(hr.Time) > time.Minute || !strings.HasSuffix(hr.Caller, "TestHumanParserRoundtrip") || hr.File != "human_parser_test.go" || hr.Line != line + 1 {
+		t.Errorf("unexpected header: %v %q %q %d", hr.Time, hr.Caller, hr.File, hr.Line)
+	}
+
+	expected := slog.Group("c",
+		slog.Int64("a", 1),
+		slog.String("b", "two words"),
+		slog.Group("g", slog.Float64("f", 1.5), slog.Bool("t", true)),
+	)
+	if len(hr.Attrs) != 1 || !hr.Attrs[0].Equal(expected) {
+		t.Errorf("unexpected attributes: %v", hr.Attrs)
+	}
+
+	hr, err = p.Parse(ls[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hr.Message != "bye" || hr.Level != LevelTrace || len(hr.Attrs) != 0 {
+		t.Errorf("unexpected record: %+v", hr)
+	}
+}
+
+func TestHumanToJSON(t *testing.T) {
+	in := strings.Join([]string {
+		"\x1b[2m20240102T030405Z\x1b[0m:7:main.main:main.go:12:\x1b[32mINFO\x1b[0m started (port: 8080)",
+		"20240102T030406Z:foo.bar:foo.go:3:ERROR failed (err: boom) (req: (id: abc))",
+	}, "\n")
+
+	var out bytes.Buffer
+	p := HumanParser {}
+	if err := p.HumanToJSON(&out, strings.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"time":"2024-01-02T03:04:05Z","level":"INFO","msg":"started","port":8080,"caller":{"name":"main.main","file":"main.go","line":12},"pid":7}
+{"time":"2024-01-02T03:04:06Z","level":"ERROR","msg":"failed","err":"boom","req":{"id":"abc"},"caller":{"name":"foo.bar","file":"foo.go","line":3}}
+`
+	if out.String() != expected {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestHumanParserHeaders(t *testing.T) {
+	ts := time.Date(2026, 10, 19, 6, 24, 1, 0, time.UTC)
+	for _, c := range []struct {
+		fields HumanHandlerFields
+		line string
+		time time.Time
+		pid int
+		caller string
+		file string
+		lineno int
+		fails bool
+	} {
+		{ line: "20261019T062401Z:INFO msg", time: ts, pid: -1, lineno: -1 },
+		{ line: "20261019T062401Z:24618:INFO suppressed 3 records", time: ts, pid: 24618, lineno: -1 },
+		{ line: "20261019T062401Z:24618:main.main:main.go:12:INFO msg", time: ts, pid: 24618, caller: "main.main", file: "main.go", lineno: 12 },
+		{ line: "20261019T062401Z:main.main:main.go:12:INFO msg", time: ts, pid: -1, caller: "main.main", file: "main.go", lineno: 12 },
+		{ fields: HumanHandlerFields { OmitTime: true }, line: "24618:main.main:main.go:12:INFO msg", pid: 24618, caller: "main.main", file: "main.go", lineno: 12 },
+		{ fields: HumanHandlerFields { OmitTime: true }, line: "24618:INFO msg", pid: 24618, lineno: -1 },
+		{ line: "20261019T062401Z:pid:INFO msg", fails: true },
+		{ line: "20261019T062401Z:main.main:main.go:twelve:INFO msg", fails: true },
+		{ line: "20261019T062401Z:1:2:main.main:main.go:12:INFO msg", fails: true },
+	} {
+		p := HumanParser { Fields: c.fields }
+		hr, err := p.Parse(c.line)
+		if c.fails {
+			if err == nil {
+				t.Errorf("unexpected success parsing: %q", c.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unable to parse %q: %v", c.line, err)
+			continue
+		}
+
+		if !hr.Time.Equal(c.time) || hr.PID != c.pid || hr.Caller != c.caller || hr.File != c.file || hr.Line != c.lineno || hr.Level != LevelInfo {
+			t.Errorf("unexpected header of %q: %+v", c.line, hr)
+		}
+	}
+}
+
+func TestHumanParserTimeLayouts(t *testing.T) {
+	ts := time.Date(2026, 10, 19, 6, 24, 1, 500000000, time.UTC)
+	for _, c := range []struct {
+		layout string
+		fields HumanHandlerFields
+	} {
+		{ layout: time.DateTime },
+		{ layout: time.RFC3339Nano },
+		{ layout: time.RFC1123 },
+		{ layout: time.DateTime, fields: HumanHandlerFields { OmitPID: true, OmitCaller: true, OmitLevel: true } },
+	} {
+		var buf bytes.Buffer
+		c.fields.Color = ColorNever
+		h := NewHumanHandler(&buf, LevelTrace, c.fields)
+		h.TimeLayout = c.layout
+
+		r := slog.NewRecord(ts, slog.LevelWarn, "hello world", 0)
+		r.AddAttrs(slog.Int("a", 1), slog.Group("caller", slog.String("name", "main.main"), slog.String("file", "main.go"), slog.Int("line", 12)), slog.Int("pid", 7))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+
+		line := strings.TrimSuffix(buf.String(), "\n")
+		p := HumanParser { Fields: c.fields, TimeLayout: c.layout }
+		hr, err := p.Parse(line)
+		if err != nil {
+			t.Errorf("unable to parse %q: %v", line, err)
+			continue
+		}
+
+		expected := HumanRecord { Time: ts.Truncate(time.Second), PID: -1, Line: -1, Message: "hello world" }
+		if c.layout == time.RFC3339Nano {
+			expected.Time = ts
+		}
+		if !c.fields.OmitPID {
+			expected.PID = 7
+		}
+		if !c.fields.OmitCaller {
+			expected.Caller, expected.File, expected.Line = "main.main", "main.go", 12
+		}
+		if !c.fields.OmitLevel {
+			expected.Level = LevelWarn
+		}
+		if !hr.Time.Equal(expected.Time) || hr.PID != expected.PID || hr.Caller != expected.Caller || hr.File != expected.File || hr.Line != expected.Line || hr.Level != expected.Level || hr.Message != expected.Message {
+			t.Errorf("unexpected record of %q: %+v", line, hr)
+		}
+		if len(hr.Attrs) != 1 || !hr.Attrs[0].Equal(slog.Int64("a", 1)) {
+			t.Errorf("unexpected attributes of %q: %v", line, hr.Attrs)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournaldSocket is the socket of journald's native protocol
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter sends entries to journald, one per write.
+type JournaldWriter struct {
+	mu sync.Mutex
+	conn *net.UnixConn
+}
+
+// DialJournald connects to journald at path, or JournaldSocket if empty.
+func DialJournald(path string) (*JournaldWriter, error) {
+	if path == "" {
+		path = JournaldSocket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr { Name: path, Net: "unixgram" })
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldWriter { conn: conn }, nil
+}
+
+func (w *JournaldWriter) Write(entry []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return 0, fs.ErrClosed
+	}
+
+	if err := writeJournal(w.conn, entry); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+func (w *JournaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// JournaldHandler writes records as journal entries, with the attributes as
+// fields named by their upper cased keys, groups joined by underscores (e.g.
+// REQUEST_ID for the id attribute in the request group), and the caller as
+// CODE_FUNC, CODE_FILE and CODE_LINE.
+type JournaldHandler struct {
+	w io.Writer
+	Level slog.Leveler
+	// Identifier is SYSLOG_IDENTIFIER, by default the name of the executable
+	Identifier string
+
+	fields flatFields
+}
+
+func NewJournaldHandler(w io.Writer, level slog.Leveler) *JournaldHandler {
+	return &JournaldHandler {
+		w: w,
+		Level: level,
+		Identifier: filepath.Base(os.Args[0]),
+	}
+}
+
+func (h *JournaldHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	min := slog.LevelInfo
+	if h.Level != nil {
+		min = h.Level.Level()
+	}
+	return lvl >= min
+}
+
+func (h0 *JournaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h1 := *h0
+	h1.fields = h0.fields.withAttrs(attrs)
+	return &h1
+}
+
+func (h0 *JournaldHandler) WithGroup(name string) slog.Handler {
+	h1 := *h0
+	h1.fields = h0.fields.withGroup(name)
+	return &h1
+}
+
+// journalKey returns a valid field name: upper case letters, digits and
+// underscores, not starting with an underscore (reserved for trusted fields)
+// or a digit, and at most 64 characters
+func journalKey(k string) string {
+	bs := []byte(strings.ToUpper(k))
+	for i, b := range bs {
+		if !(b >= 'A' && b <= 'Z' || b >= '0' && b <= '9') {
+			bs[i] = '_'
+		}
+	}
+
+	k = strings.TrimLeft(string(bs), "_")
+	if k == "" || k[0] >= '0' && k[0] <= '9' {
+		k = "F" + k
+	}
+	return k[:min(len(k), 64)]
+}
+
+func journalField(b *bytes.Buffer, k, v string) {
+	if !strings.Contains(v, "\n") {
+		b.WriteString(k + "=" + v + "\n")
+		return
+	}
+
+	b.WriteString(k + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(v)))
+	b.WriteString(v + "\n")
+}
+
+func (h *JournaldHandler) Handle(_ context.Context, r slog.Record) error {
+	pid, caller, fs := h.fields.record(r)
+
+	var b bytes.Buffer
+	journalField(&b, "MESSAGE", r.Message)
+	journalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(r.Level)))
+	journalField(&b, "LEVEL", Level(r.Level).String())
+	if h.Identifier != "" {
+		journalField(&b, "SYSLOG_IDENTIFIER", h.Identifier)
+	}
+	if pid >= 0 {
+		journalField(&b, "SYSLOG_PID", strconv.FormatInt(pid, 10))
+	}
+	if caller.name != "" {
+		journalField(&b, "CODE_FUNC", caller.name)
+	}
+	if caller.file != "" {
+		journalField(&b, "CODE_FILE", caller.file)
+	}
+	if caller.line >= 0 {
+		journalField(&b, "CODE_LINE", strconv.FormatInt(caller.line, 10))
+	}
+
+	for _, f := range fs {
+		journalField(&b, journalKey(f.key), fieldString(f.value))
+	}
+
+	_, err := h.w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package logging
+
+import (
+	"net"
+)
+
+// writeJournal sends the entry as a datagram, since descriptors can not be
+// passed for entries too large for one
+func writeJournal(conn *net.UnixConn, entry []byte) error {
+	_, err := conn.Write(entry)
+	return err
+}
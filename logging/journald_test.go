@@ -0,0 +1,151 @@
+//go:build unix
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func parseJournalEntry(t *testing.T, bs []byte) map[string]string {
+	fs := make(map[string]string)
+	for len(bs) > 0 {
+		i := bytes.IndexAny(bs, "=\n")
+		if i < 0 {
+			t.Fatalf("malformed entry: %q", bs)
+		}
+
+		k := string(bs[:i])
+		if bs[i] == '=' {
+			j := bytes.IndexByte(bs, '\n')
+			fs[k], bs = string(bs[i+1:j]), bs[j+1:]
+		} else {
+			n := binary.LittleEndian.Uint64(bs[i+1:])
+			bs = bs[i+9:]
+			fs[k], bs = string(bs[:n]), bs[n+1:]
+		}
+	}
+	return fs
+}
+
+func TestJournald(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr { Name: path, Net: "unixgram" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := DialJournald(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	h := NewJournaldHandler(w, LevelTrace)
+	h.Identifier = "test"
+	logger, _, err := (&Config { Handlers: []slog.Handler { h } }).SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.WithGroup("req").Trace("hello\nworld", "id", 7, "2fa", true)
+
+	bs := make([]byte, 4096)
+	n, err := l.Read(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := parseJournalEntry(t, bs[:n])
+
+	for k, v := range map[string]string {
+		"MESSAGE": "hello\nworld",
+		"PRIORITY": "7",
+		"LEVEL": "TRACE",
+		"SYSLOG_IDENTIFIER": "test",
+		"CODE_FUNC": "rootmos.io/go-utils/logging.TestJournald",
+		"REQ_ID": "7",
+		"REQ_2FA": "true",
+	} {
+		if fs[k] != v {
+			t.Errorf("unexpected %s: %q != %q", k, fs[k], v)
+		}
+	}
+	if !strings.HasSuffix(fs["CODE_FILE"], "journald_test.go") {
+		t.Errorf("unexpected CODE_FILE: %q", fs["CODE_FILE"])
+	}
+}
+
+func TestJournaldLarge(t *testing.T) {
+	if _, err := os.Stat("/dev/shm"); err != nil {
+		t.Skip("/dev/shm not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "socket")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr { Name: path, Net: "unixgram" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := DialJournald(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	msg := strings.Repeat("x", 1 << 20)
+	if err := NewJournaldHandler(w, LevelInfo).Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, msg, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := l.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmsgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(cmsgs) != 1 {
+		t.Fatalf("unexpected control messages: %v %v", cmsgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&cmsgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("unexpected rights: %v %v", fds, err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "entry")
+	defer f.Close()
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs := parseJournalEntry(t, bs); fs["MESSAGE"] != msg {
+		t.Errorf("unexpected message of length %d", len(fs["MESSAGE"]))
+	}
+}
+
+func TestJournalKey(t *testing.T) {
+	for k, e := range map[string]string {
+		"req.id": "REQ_ID",
+		"_secret": "SECRET",
+		"2fa": "F2FA",
+		"ünicode": "NICODE",
+	} {
+		if a := journalKey(k); a != e {
+			t.Errorf("unexpected key of %s: %s != %s", k, a, e)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package logging
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// writeJournal sends the entry as a datagram, or when too large, as sd-journal
+// does, in an unlinked file in /dev/shm whose descriptor is passed instead.
+func writeJournal(conn *net.UnixConn, entry []byte) error {
+	_, err := conn.Write(entry)
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+
+	f, err := os.CreateTemp("/dev/shm", "journal.*")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	if _, err := f.Write(entry); err != nil {
+		return err
+	}
+
+	// WriteMsgUnix refuses connected datagram sockets
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	if werr := rc.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != syscall.EAGAIN
+	}); werr != nil {
+		return werr
+	}
+	return err
+}
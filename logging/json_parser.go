@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// ParseJSONRecord parses a line written by the JSON handler set up by Config
+// back into a record, keeping the order of the attributes. Objects become
+// groups, and numbers become Int64 values when integral, otherwise Float64.
+func ParseJSONRecord(line []byte) (r slog.Record, err error) {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+
+	if t, err := d.Token(); err != nil {
+		return r, err
+	} else if t != json.Delim('{') {
+		return r, fmt.Errorf("expected JSON object")
+	}
+
+	var attrs []slog.Attr
+	for d.More() {
+		k, err := d.Token()
+		if err != nil {
+			return r, err
+		}
+
+		v, err := decodeValue(d)
+		if err != nil {
+			return r, err
+		}
+
+		switch k {
+		case slog.TimeKey:
+			if v.Kind() != slog.KindString {
+				return r, fmt.Errorf("unexpected time: %v", v)
+			}
+			if r.Time, err = time.Parse(time.RFC3339Nano, v.String()); err != nil {
+				return r, err
+			}
+		case slog.LevelKey:
+			l, err := ParseLevel(v.String())
+			if err != nil {
+				return r, err
+			}
+			r.Level = slog.Level(l)
+		case slog.MessageKey:
+			r.Message = v.String()
+		default:
+			attrs = append(attrs, slog.Attr { Key: k.(string), Value: v })
+		}
+	}
+
+	if _, err := d.Token(); err != nil {
+		return r, err
+	}
+
+	r.AddAttrs(liftCallerPID(attrs)...)
+	return r, nil
+}
+
+// liftCallerPID moves the caller and pid attributes added by Logger out of the
+// groups the JSON handler nests them in (as any other attribute of the record)
+// to the top level, where HumanHandler expects them.
+func liftCallerPID(as []slog.Attr) []slog.Attr {
+	var lifted []slog.Attr
+	var f func(as []slog.Attr) []slog.Attr
+	f = func(as []slog.Attr) []slog.Attr {
+		var rest []slog.Attr
+		for _, a := range as {
+			if (a.Key == "caller" && a.Value.Kind() == slog.KindGroup) || (a.Key == "pid" && a.Value.Kind() == slog.KindInt64) {
+				lifted = append(lifted, a)
+			} else {
+				rest = append(rest, a)
+			}
+		}
+
+		if len(lifted) == 0 && len(rest) > 0 {
+			if l := rest[len(rest)-1]; l.Value.Kind() == slog.KindGroup {
+				rest[len(rest)-1] = slog.Attr { Key: l.Key, Value: slog.GroupValue(f(l.Value.Group())...) }
+			}
+		}
+		return rest
+	}
+
+	rest := f(as)
+	return append(rest, lifted...)
+}
+
+func decodeValue(d *json.Decoder) (slog.Value, error) {
+	t, err := d.Token()
+	if err != nil {
+		return slog.Value{}, err
+	}
+
+	switch v := t.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			var as []slog.Attr
+			for d.More() {
+				k, err := d.Token()
+				if err != nil {
+					return slog.Value{}, err
+				}
+				a, err := decodeValue(d)
+				if err != nil {
+					return slog.Value{}, err
+				}
+				as = append(as, slog.Attr { Key: k.(string), Value: a })
+			}
+			_, err := d.Token()
+			return slog.GroupValue(as...), err
+		case '[':
+			var xs []any
+			for d.More() {
+				x, err := decodeValue(d)
+				if err != nil {
+					return slog.Value{}, err
+				}
+				xs = append(xs, x.Any())
+			}
+			_, err := d.Token()
+			return slog.AnyValue(xs), err
+		default:
+			return slog.Value{}, fmt.Errorf("unexpected delimiter: %v", v)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return slog.Int64Value(i), nil
+		}
+		f, err := v.Float64()
+		return slog.Float64Value(f), err
+	case string:
+		return slog.StringValue(v), nil
+	case bool:
+		return slog.BoolValue(v), nil
+	default:
+		return slog.AnyValue(v), nil
+	}
+}
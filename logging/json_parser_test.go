@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseJSONRecord(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config {
+		JsonWriter: &buf,
+		JsonLevel: LevelTrace,
+	}
+
+	logger, _, err := cfg.SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, line, _ := runtime.Caller(0)
+	logger.WithGroup("c").Trace("hello", "a", 1, "b", []int { 2, 3 }, slog.Group("g", "f", 1.5, "t", true))
+
+	r, err := ParseJSONRecord(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Message != "hello" || r.Level != slog.Level(LevelTrace) || time.Since(r.Time) > time.Minute {
+		t.Errorf("unexpected record: %v", r)
+	}
+
+	var out bytes.Buffer
+	h := NewHumanHandler(&out, LevelTrace, HumanHandlerFields { OmitTime: true })
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("%d:rootmos.io/go-utils/logging.TestParseJSONRecord:json_parser_test.go:%d:TRACE hello (c: (a: 1) (b: [2 3]) (g: (f: 1.5) (t: true)))\n", os.Getpid(), line + 1)
+	if out.String() != expected {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
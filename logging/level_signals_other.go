@@ -0,0 +1,18 @@
+//go:build !unix
+
+package logging
+
+import (
+	"io"
+)
+
+type levelSignals struct{}
+
+// bumpOnSignals does nothing where SIGUSR1 and SIGUSR2 are not available
+func bumpOnSignals(_ LevelVars) io.Closer {
+	return levelSignals{}
+}
+
+func (_ levelSignals) Close() error {
+	return nil
+}
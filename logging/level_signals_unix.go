@@ -0,0 +1,45 @@
+//go:build unix
+
+package logging
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type levelSignals struct {
+	ch chan os.Signal
+	done chan struct{}
+}
+
+// bumpOnSignals makes the levels more verbose on SIGUSR1 and less on SIGUSR2,
+// until closed.
+func bumpOnSignals(vs LevelVars) io.Closer {
+	s := &levelSignals {
+		ch: make(chan os.Signal, 1),
+		done: make(chan struct{}),
+	}
+	signal.Notify(s.ch, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer close(s.done)
+		for sig := range s.ch {
+			if sig == syscall.SIGUSR1 {
+				vs.Bump(-1)
+			} else {
+				vs.Bump(1)
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *levelSignals) Close() error {
+	signal.Stop(s.ch)
+	close(s.ch)
+	<-s.done
+	return nil
+}
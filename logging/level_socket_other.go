@@ -0,0 +1,23 @@
+//go:build !unix
+
+package logging
+
+import (
+	"net"
+	"os"
+)
+
+// listenPrivate listens on a Unix socket at path and restricts it to the
+// owner where permissions of sockets apply
+func listenPrivate(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,62 @@
+//go:build unix
+
+package logging
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+type privateListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l privateListener) Close() error {
+	err := l.UnixListener.Close()
+	_ = os.Remove(l.path)
+	return err
+}
+
+// listenPrivate listens on a Unix socket at path accessible only by the owner.
+// The socket is bound in a private directory and linked into place, so it is
+// never accessible with the permissions of the umask.
+func listenPrivate(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode().Perm() & 0002 != 0 && fi.Mode() & fs.ModeSticky == 0 {
+		return nil, fmt.Errorf("refusing to create a socket in a world-writable directory: %s", dir)
+	}
+
+	tmp, err := os.MkdirTemp(dir, ".socket-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	name := filepath.Join(tmp, "socket")
+	l, err := net.ListenUnix("unix", &net.UnixAddr { Name: name, Net: "unix" })
+	if err != nil {
+		return nil, err
+	}
+	l.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(name, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	// unlike a rename, linking fails instead of replacing an existing socket
+	if err := os.Link(name, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return privateListener { UnixListener: l, path: path }, nil
+}
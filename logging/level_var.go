@@ -0,0 +1,223 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// Level implements slog.Leveler so that it can be used where a LevelVar can.
+func (l Level) Level() slog.Level {
+	return slog.Level(l)
+}
+
+func (l Level) String() string {
+	if l == LevelTrace {
+		return "TRACE"
+	}
+	return slog.Level(l).String()
+}
+
+// LevelVar is a level that can be changed while logging, as slog.LevelVar.
+type LevelVar struct {
+	v slog.LevelVar
+}
+
+func NewLevelVar(l Level) *LevelVar {
+	v := &LevelVar{}
+	v.Set(l)
+	return v
+}
+
+func (v *LevelVar) Level() slog.Level {
+	return v.v.Level()
+}
+
+func (v *LevelVar) Get() Level {
+	return Level(v.v.Level())
+}
+
+func (v *LevelVar) Set(l Level) {
+	v.v.Set(slog.Level(l))
+}
+
+func (v *LevelVar) String() string {
+	return fmt.Sprintf("LevelVar(%s)", v.Get())
+}
+
+func (v *LevelVar) MarshalText() ([]byte, error) {
+	return []byte(v.Get().String()), nil
+}
+
+func (v *LevelVar) UnmarshalText(data []byte) error {
+	l, err := ParseLevel(string(data))
+	if err != nil {
+		return err
+	}
+	v.Set(l)
+	return nil
+}
+
+var bumpLevels = []Level { LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError }
+
+// Bump makes the level more verbose (towards TRACE) when steps is negative,
+// or less verbose (towards ERROR) when positive. The first step from a level
+// between the named ones is to the closest one.
+func (v *LevelVar) Bump(steps int) Level {
+	l := v.Get()
+
+	var i int
+	switch {
+	case steps < 0:
+		i = sort.Search(len(bumpLevels), func(i int) bool { return bumpLevels[i] >= l }) + steps
+	case steps > 0:
+		i = sort.Search(len(bumpLevels), func(i int) bool { return bumpLevels[i] > l }) + steps - 1
+	default:
+		return l
+	}
+	i = max(0, min(i, len(bumpLevels) - 1))
+
+	v.Set(bumpLevels[i])
+	return bumpLevels[i]
+}
+
+// LevelVars are the levels of the outputs set up by Config, e.g. human and
+// json, and serves them over HTTP: GET returns them as a JSON object, and
+// PUT or POST sets those given in a JSON object or in the query string.
+type LevelVars map[string]*LevelVar
+
+func (vs LevelVars) Bump(steps int) {
+	for _, v := range vs {
+		v.Bump(steps)
+	}
+}
+
+func (vs LevelVars) set(ls map[string]string) error {
+	parsed := make(map[string]Level)
+	for k, s := range ls {
+		if _, ok := vs[k]; !ok {
+			return fmt.Errorf("unknown output: %s", k)
+		}
+		l, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		parsed[k] = l
+	}
+
+	for k, l := range parsed {
+		vs[k].Set(l)
+	}
+	return nil
+}
+
+func (vs LevelVars) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		ls := make(map[string]string)
+		for k := range r.URL.Query() {
+			ls[k] = r.URL.Query().Get(k)
+		}
+		if r.Body != nil {
+			bs, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if len(bs) > 0 {
+				if err := json.Unmarshal(bs, &ls); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		if err := vs.set(ls); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(vs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// removeStaleSocket removes the socket at path if nothing accepts connections
+// on it, e.g. when left behind by a crashed process
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Type() != fs.ModeSocket {
+		return nil
+	}
+
+	c, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		c.Close()
+		return nil
+	}
+	return os.Remove(path)
+}
+
+type levelsServer struct {
+	srv *http.Server
+	done chan struct{}
+}
+
+// ServeLevels serves the levels over HTTP on a Unix socket, e.g.
+//
+//	curl --unix-socket path -X PUT http://_/?human=DEBUG
+//
+// A socket left behind by a previous process is replaced, and the socket is
+// only ever accessible by the owner. Directories writable by anyone, except
+// sticky ones such as /tmp, are refused.
+func ServeLevels(path string, vs LevelVars) (io.Closer, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := listenPrivate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &levelsServer {
+		srv: &http.Server { Handler: vs },
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		if err := s.srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "unable to serve log levels: %v\n", err)
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *levelsServer) Close() error {
+	err := s.srv.Close()
+	<-s.done
+	return err
+}
@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBump(t *testing.T) {
+	v := NewLevelVar(LevelInfo)
+	for _, expected := range []Level { LevelDebug, LevelTrace, LevelTrace } {
+		if l := v.Bump(-1); l != expected {
+			t.Errorf("unexpected level: %v != %v", l, expected)
+		}
+	}
+
+	v.Set(LevelInfo + 2)
+	if l := v.Bump(1); l != LevelWarn {
+		t.Errorf("unexpected level: %v", l)
+	}
+	if l := v.Bump(7); l != LevelError {
+		t.Errorf("unexpected level: %v", l)
+	}
+
+	v.Set(LevelInfo + 2)
+	if l := v.Bump(-1); l != LevelInfo {
+		t.Errorf("unexpected level: %v", l)
+	}
+}
+
+func TestLevelVars(t *testing.T) {
+	var sb strings.Builder
+	cfg := Config {
+		HumanWriter: &sb,
+		HumanFields: HumanHandlerFields { OmitTime: true, OmitPID: true, OmitCaller: true },
+		HumanModuleLevels: map[string]Level { "osext": LevelTrace },
+	}
+
+	logger, _, err := cfg.SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Debug("before")
+	logger.Levels()["human"].Set(LevelDebug)
+	logger.Debug("after")
+
+	if sb.String() != "DEBUG after\n" {
+		t.Errorf("unexpected output: %q", sb.String())
+	}
+}
+
+func TestServeLevels(t *testing.T) {
+	vs := LevelVars { "human": NewLevelVar(LevelInfo), "json": NewLevelVar(LevelTrace) }
+
+	path := filepath.Join(t.TempDir(), "control")
+	srv, err := ServeLevels(path, vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	c := http.Client {
+		Transport: &http.Transport {
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	do := func(method, url, body string) (int, string) {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+		bs, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp.StatusCode, string(bs)
+	}
+
+	if code, body := do(http.MethodGet, "http://_/", ""); code != http.StatusOK || body != `{"human":"INFO","json":"TRACE"}` + "\n" {
+		t.Errorf("unexpected response: %d %q", code, body)
+	}
+
+	if code, body := do(http.MethodPut, "http://_/?human=debug", ""); code != http.StatusOK || vs["human"].Get() != LevelDebug {
+		t.Errorf("unexpected response: %d %q", code, body)
+	}
+
+	if code, _ := do(http.MethodPost, "http://_/", `{"json":"WARN"}`); code != http.StatusOK || vs["json"].Get() != LevelWarn {
+		t.Errorf("unexpected response: %d %v", code, vs["json"])
+	}
+
+	if code, _ := do(http.MethodPut, "http://_/?foo=INFO", ""); code != http.StatusBadRequest {
+		t.Errorf("unexpected response: %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	vs.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected response: %d", rec.Code)
+	}
+}
+
+func TestServeLevelsSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control")
+
+	// a socket left behind by a crashed process
+	l, err := net.ListenUnix("unix", &net.UnixAddr { Name: path, Net: "unix" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetUnlinkOnClose(false)
+	l.Close()
+
+	srv, err := ServeLevels(path, LevelVars {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected permissions: %v", fi.Mode().Perm())
+	}
+
+	// a socket in use is not replaced
+	if srv2, err := ServeLevels(path, LevelVars {}); err == nil {
+		srv2.Close()
+		t.Errorf("unexpected success serving on a socket in use")
+	}
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if srv3, err := ServeLevels(filepath.Join(dir, "control"), LevelVars {}); err == nil {
+		srv3.Close()
+		t.Errorf("unexpected success serving in a world-writable directory")
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("socket left behind: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LogfmtHandler writes records as logfmt lines, e.g.
+//
+//	time=2024-01-02T03:04:05Z pid=7 caller.name=main.main caller.file=main.go caller.line=12 level=INFO msg="hello world" req.id=8
+//
+// with the groups joined by dots. The fields are omitted as by HumanHandler.
+type LogfmtHandler struct {
+	w io.Writer
+
+	// Level is e.g. a Level or a LevelVar
+	Level slog.Leveler
+	Fields HumanHandlerFields
+	// TimeLayout defaults to RFC3339 with nanoseconds
+	TimeLayout string
+
+	fields flatFields
+}
+
+func NewLogfmtHandler(w io.Writer, level slog.Leveler, fields HumanHandlerFields) *LogfmtHandler {
+	return &LogfmtHandler {
+		w: w,
+		Level: level,
+		Fields: fields,
+	}
+}
+
+func (h *LogfmtHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	min := slog.LevelInfo
+	if h.Level != nil {
+		min = h.Level.Level()
+	}
+	return h.w != nil && lvl >= min
+}
+
+func (h0 *LogfmtHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h1 := *h0
+	h1.fields = h0.fields.withAttrs(attrs)
+	return &h1
+}
+
+func (h0 *LogfmtHandler) WithGroup(name string) slog.Handler {
+	h1 := *h0
+	h1.fields = h0.fields.withGroup(name)
+	return &h1
+}
+
+// logfmtKey replaces the characters not allowed in keys with underscores
+func logfmtKey(k string) string {
+	if k == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, k)
+}
+
+// logfmtValue quotes the value if empty or containing spaces, equal signs,
+// quotes or unprintable characters
+func logfmtValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+	if strings.IndexFunc(v, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == unicode.ReplacementChar || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+func (h *LogfmtHandler) Handle(_ context.Context, r slog.Record) error {
+	pid, caller, fs := h.fields.record(r)
+
+	var b bytes.Buffer
+	add := func(k, v string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(logfmtKey(k) + "=" + logfmtValue(v))
+	}
+
+	if !h.Fields.OmitTime && !r.Time.IsZero() {
+		layout := h.TimeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		add("time", r.Time.UTC().Format(layout))
+	}
+
+	if !h.Fields.OmitPID && pid >= 0 {
+		add("pid", strconv.FormatInt(pid, 10))
+	}
+
+	if !h.Fields.OmitCaller {
+		if caller.name != "" {
+			add("caller.name", caller.name)
+		}
+		if caller.file != "" {
+			add("caller.file", maybeRelPath(caller.file))
+		}
+		if caller.line >= 0 {
+			add("caller.line", strconv.FormatInt(caller.line, 10))
+		}
+	}
+
+	if !h.Fields.OmitLevel {
+		add("level", Level(r.Level).String())
+	}
+
+	add("msg", r.Message)
+
+	for _, f := range fs {
+		add(f.key, fieldString(f.value))
+	}
+
+	b.WriteByte('\n')
+	_, err := h.w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func ExampleLogfmtHandler() {
+	cfg := Config {
+		HumanWriter: os.Stdout,
+		HumanFormat: FormatLogfmt,
+		HumanFields: HumanHandlerFields {
+			OmitTime: true,
+			OmitPID: true,
+		},
+		HumanLevel: LevelTrace,
+	}
+
+	logger, closer, err := cfg.SetupLogger()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closer()
+
+	logger.Info("hello world", "a", 8)
+	logger.WithGroup("c").Warn("baz", "d", true, slog.Group("g", "f", 11))
+	logger.With("b", `say "hi"`).Trace("bye", "e", "")
+
+	// Output:
+	// caller.name=rootmos.io/go-utils/logging.ExampleLogfmtHandler caller.file=logfmt_handler_test.go caller.line=27 level=INFO msg="hello world" a=8
+	// caller.name=rootmos.io/go-utils/logging.ExampleLogfmtHandler caller.file=logfmt_handler_test.go caller.line=28 level=WARN msg=baz c.d=true c.g.f=11
+	// caller.name=rootmos.io/go-utils/logging.ExampleLogfmtHandler caller.file=logfmt_handler_test.go caller.line=29 level=TRACE msg=bye b="say \"hi\"" e=""
+}
+
+func TestLogfmtQuoting(t *testing.T) {
+	for v, e := range map[string]string {
+		"plain": "plain",
+		"": `""`,
+		"a b": `"a b"`,
+		"a=b": `"a=b"`,
+		"line\nbreak": `"line\nbreak"`,
+		`back\slash`: `"back\\slash"`,
+		"ünicode": "ünicode",
+		"\x00": `"\x00"`,
+	} {
+		if a := logfmtValue(v); a != e {
+			t.Errorf("unexpected value of %q: %s != %s", v, a, e)
+		}
+	}
+
+	for k, e := range map[string]string {
+		"a.b": "a.b",
+		"a b": "a_b",
+		"a=b": "a_b",
+		"": "_",
+	} {
+		if a := logfmtKey(k); a != e {
+			t.Errorf("unexpected key of %q: %s != %s", k, a, e)
+		}
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"log/slog"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,11 +18,28 @@ const Key = "logger"
 
 type Logger struct {
 	inner *slog.Logger
+	levels LevelVars
+	closer func() error
 
 	ExitWriter io.Writer
 	ExitLevel Level
 }
 
+// Levels returns the levels of the outputs set up by Config, which can be
+// changed while logging.
+func (l *Logger) Levels() LevelVars {
+	return l.levels
+}
+
+// Close closes the handlers and files set up by Config, as the closer
+// returned by SetupLogger, and is called by Exit before exiting.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer()
+}
+
 func (l0 *Logger) With(args ...any) *Logger {
 	l1 := *l0
 	l1.inner = l1.inner.With(args...)
@@ -62,7 +81,7 @@ type Level slog.Level
 //go:generate ./generate_level.sh levels.go Warn slog.LevelWarn
 //go:generate ./generate_level.sh levels.go Error slog.LevelError
 
-func parseLogLevel(s string) (Level, error) {
+func ParseLevel(s string) (Level, error) {
 	switch strings.ToUpper(s) {
 	case "TRACE":
 		return LevelTrace, nil
@@ -76,18 +95,98 @@ func parseLogLevel(s string) (Level, error) {
 	return Level(l), nil
 }
 
+type Format int
+
+const (
+	FormatHuman Format = iota
+	FormatLogfmt
+	FormatJSON
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "human", "text":
+		return FormatHuman, nil
+	case "logfmt":
+		return FormatLogfmt, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return 0, fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
 type Config struct {
 	HumanWriter io.Writer
+	// HumanFormat is the format of the records written to HumanWriter
+	HumanFormat Format
+	humanFormatFlag *string
 	humanLevelFlag *string
 	HumanLevel Level
+	// HumanModuleLevels overrides HumanLevel for modules (see ModuleLevels)
+	HumanModuleLevels map[string]Level
 	humanFileFlag *string
 	HumanFields HumanHandlerFields
 
 	JsonWriter io.Writer
 	jsonLevelFlag *string
 	JsonLevel Level
+	JsonModuleLevels map[string]Level
 	jsonFileFlag *string
 
+	// Syslog, if set, is the address of a syslog server (see DialSyslog)
+	Syslog string
+	syslogFlag *string
+	syslogLevelFlag *string
+	SyslogLevel Level
+	SyslogModuleLevels map[string]Level
+	SyslogOptions SyslogOptions
+	syslogFacilityFlag *string
+
+	// Journald logs to the systemd journal
+	Journald bool
+	journaldFlag *string
+	journaldLevelFlag *string
+	JournaldLevel Level
+	JournaldModuleLevels map[string]Level
+
+	// Rotate applies to the files opened for --log-file and --json-log-file
+	Rotate RotateOptions
+	rotateSizeFlag *string
+	rotateIntervalFlag *string
+	rotateKeepFlag *string
+	rotateCompressFlag *string
+
+	// ReopenOnSIGHUP reopens the log files on SIGHUP, e.g. for logrotate
+	ReopenOnSIGHUP bool
+	reopenFlag *string
+
+	// AsyncQueueSize, if positive, hands records to the handlers through a
+	// queue of that size (see AsyncHandler), flushed by the closer
+	AsyncQueueSize int
+	asyncQueueSizeFlag *string
+	AsyncPolicy AsyncPolicy
+	asyncDropFlag *string
+
+	// Sampling, if set, passes only a sample of the records to the handlers
+	// (see SamplingHandler)
+	Sampling *SamplingOptions
+	sampleFirstFlag *string
+	sampleThereafterFlag *string
+	sampleIntervalFlag *string
+	sampleMaxLevelFlag *string
+	rateLimitsFlag *string
+
+	// ControlSocket, if set, is the path of a Unix socket serving the levels
+	// over HTTP (see LevelVars)
+	ControlSocket string
+	controlSocketFlag *string
+
+	// LevelSignals makes the levels more verbose on SIGUSR1 and less verbose
+	// on SIGUSR2
+	LevelSignals bool
+	levelSignalsFlag *string
+
 	ExitWriter io.Writer
 	ExitLevel Level
 
@@ -97,6 +196,8 @@ type Config struct {
 var (
 	DefaultHumanLevel = "INFO"
 	DefaultJsonLevel = "INFO"
+	DefaultSyslogLevel = "INFO"
+	DefaultJournaldLevel = "INFO"
 )
 
 func PrepareConfig(envPrefix string) Config {
@@ -109,21 +210,177 @@ func PrepareConfig(envPrefix string) Config {
 		}
 	}
 	return Config {
-		humanLevelFlag: flag.String("log-level", getenv("LOG_LEVEL", DefaultHumanLevel), "set log level"),
+		humanLevelFlag: flag.String("log-level", getenv("LOG_LEVEL", DefaultHumanLevel), "set log level, optionally per module (e.g. INFO,osext=DEBUG)"),
 		humanFileFlag: flag.String("log-file", getenv("LOG_FILE", "/dev/stderr"), "log to file"),
+		humanFormatFlag: flag.String("log-format", getenv("LOG_FORMAT", ""), "format of --log-file (human, logfmt or json)"),
 
-		jsonLevelFlag: flag.String("json-log-level", getenv("JSON_LOG_LEVEL", DefaultJsonLevel), "set JSON log level"),
+		jsonLevelFlag: flag.String("json-log-level", getenv("JSON_LOG_LEVEL", DefaultJsonLevel), "set JSON log level, optionally per module"),
 		jsonFileFlag: flag.String("json-log-file", getenv("JSON_LOG_FILE", "/dev/null"), "log JSON to file"),
 
+		syslogFlag: flag.String("log-syslog", getenv("LOG_SYSLOG", ""), "log to syslog at address (e.g. unix:///dev/log, udp://localhost:514 or tcp://localhost:514)"),
+		syslogLevelFlag: flag.String("log-syslog-level", getenv("LOG_SYSLOG_LEVEL", DefaultSyslogLevel), "set syslog log level, optionally per module"),
+		syslogFacilityFlag: flag.String("log-syslog-facility", getenv("LOG_SYSLOG_FACILITY", ""), "syslog facility (default user)"),
+
+		journaldFlag: flag.String("log-journald", getenv("LOG_JOURNALD", ""), "log to the systemd journal"),
+		journaldLevelFlag: flag.String("log-journald-level", getenv("LOG_JOURNALD_LEVEL", DefaultJournaldLevel), "set journald log level, optionally per module"),
+
+		rotateSizeFlag: flag.String("log-rotate-size", getenv("LOG_ROTATE_SIZE", ""), "rotate log files exceeding size (e.g. 100M)"),
+		rotateIntervalFlag: flag.String("log-rotate-interval", getenv("LOG_ROTATE_INTERVAL", ""), "rotate log files after duration (e.g. 24h)"),
+		rotateKeepFlag: flag.String("log-rotate-keep", getenv("LOG_ROTATE_KEEP", ""), "number of rotated log files to keep"),
+		rotateCompressFlag: flag.String("log-rotate-compress", getenv("LOG_ROTATE_COMPRESS", ""), "gzip rotated log files"),
+		reopenFlag: flag.String("log-reopen-on-sighup", getenv("LOG_REOPEN_ON_SIGHUP", ""), "reopen log files on SIGHUP"),
+
+		asyncQueueSizeFlag: flag.String("log-async-queue", getenv("LOG_ASYNC_QUEUE", ""), "log asynchronously through a queue of size"),
+		asyncDropFlag: flag.String("log-async-drop", getenv("LOG_ASYNC_DROP", ""), "drop records when the asynchronous queue is full instead of blocking"),
+
+		sampleFirstFlag: flag.String("log-sample-first", getenv("LOG_SAMPLE_FIRST", ""), "pass the first n records of the same message and caller per interval"),
+		sampleThereafterFlag: flag.String("log-sample-thereafter", getenv("LOG_SAMPLE_THEREAFTER", ""), "then pass every n:th record of the same message and caller"),
+		sampleIntervalFlag: flag.String("log-sample-interval", getenv("LOG_SAMPLE_INTERVAL", ""), "sampling interval (default 1s)"),
+		sampleMaxLevelFlag: flag.String("log-sample-max-level", getenv("LOG_SAMPLE_MAX_LEVEL", ""), "sample only records of at most level (default DEBUG)"),
+		controlSocketFlag: flag.String("log-control-socket", getenv("LOG_CONTROL_SOCKET", ""), "serve log levels over HTTP on Unix socket"),
+		levelSignalsFlag: flag.String("log-level-signals", getenv("LOG_LEVEL_SIGNALS", ""), "bump log levels down on SIGUSR1 and up on SIGUSR2"),
+
+		rateLimitsFlag: flag.String("log-rate-limit", getenv("LOG_RATE_LIMIT", ""), "limit the records per sampling interval of levels (e.g. DEBUG=100,TRACE=10)"),
+
 		ExitWriter: os.Stderr,
 		ExitLevel: LevelDebug,
 	}
 }
 
+func (c *Config) parseFlags() (err error) {
+	if c.humanFormatFlag != nil && *c.humanFormatFlag != "" {
+		if c.HumanFormat, err = ParseFormat(*c.humanFormatFlag); err != nil {
+			return err
+		}
+	}
+	if c.rotateSizeFlag != nil && *c.rotateSizeFlag != "" {
+		if c.Rotate.MaxSize, err = ParseSize(*c.rotateSizeFlag); err != nil {
+			return err
+		}
+	}
+	if c.rotateIntervalFlag != nil && *c.rotateIntervalFlag != "" {
+		if c.Rotate.MaxAge, err = time.ParseDuration(*c.rotateIntervalFlag); err != nil {
+			return err
+		}
+	}
+	if c.rotateKeepFlag != nil && *c.rotateKeepFlag != "" {
+		if c.Rotate.Keep, err = strconv.Atoi(*c.rotateKeepFlag); err != nil {
+			return err
+		}
+	}
+	if c.rotateCompressFlag != nil && *c.rotateCompressFlag != "" {
+		if c.Rotate.Compress, err = strconv.ParseBool(*c.rotateCompressFlag); err != nil {
+			return err
+		}
+	}
+	if c.reopenFlag != nil && *c.reopenFlag != "" {
+		if c.ReopenOnSIGHUP, err = strconv.ParseBool(*c.reopenFlag); err != nil {
+			return err
+		}
+	}
+	if c.asyncQueueSizeFlag != nil && *c.asyncQueueSizeFlag != "" {
+		if c.AsyncQueueSize, err = strconv.Atoi(*c.asyncQueueSizeFlag); err != nil {
+			return err
+		}
+	}
+	if c.asyncDropFlag != nil && *c.asyncDropFlag != "" {
+		drop, err := strconv.ParseBool(*c.asyncDropFlag)
+		if err != nil {
+			return err
+		}
+		if drop {
+			c.AsyncPolicy = AsyncDrop
+		} else {
+			c.AsyncPolicy = AsyncBlock
+		}
+	}
+
+	if c.syslogFlag != nil && *c.syslogFlag != "" {
+		c.Syslog = *c.syslogFlag
+	}
+	if c.syslogFacilityFlag != nil && *c.syslogFacilityFlag != "" {
+		if c.SyslogOptions.Facility, err = ParseFacility(*c.syslogFacilityFlag); err != nil {
+			return err
+		}
+	}
+	if c.journaldFlag != nil && *c.journaldFlag != "" {
+		if c.Journald, err = strconv.ParseBool(*c.journaldFlag); err != nil {
+			return err
+		}
+	}
+
+	if c.controlSocketFlag != nil && *c.controlSocketFlag != "" {
+		c.ControlSocket = *c.controlSocketFlag
+	}
+	if c.levelSignalsFlag != nil && *c.levelSignalsFlag != "" {
+		if c.LevelSignals, err = strconv.ParseBool(*c.levelSignalsFlag); err != nil {
+			return err
+		}
+	}
+
+	sampling := func() *SamplingOptions {
+		if c.Sampling == nil {
+			c.Sampling = &SamplingOptions {}
+		}
+		return c.Sampling
+	}
+	if c.sampleFirstFlag != nil && *c.sampleFirstFlag != "" {
+		if sampling().First, err = strconv.Atoi(*c.sampleFirstFlag); err != nil {
+			return err
+		}
+	}
+	if c.sampleThereafterFlag != nil && *c.sampleThereafterFlag != "" {
+		if sampling().Thereafter, err = strconv.Atoi(*c.sampleThereafterFlag); err != nil {
+			return err
+		}
+	}
+	if c.sampleIntervalFlag != nil && *c.sampleIntervalFlag != "" {
+		if sampling().Interval, err = time.ParseDuration(*c.sampleIntervalFlag); err != nil {
+			return err
+		}
+	}
+	if c.sampleMaxLevelFlag != nil && *c.sampleMaxLevelFlag != "" {
+		l, err := ParseLevel(*c.sampleMaxLevelFlag)
+		if err != nil {
+			return err
+		}
+		sampling().MaxLevel = &l
+	}
+	if c.rateLimitsFlag != nil && *c.rateLimitsFlag != "" {
+		if sampling().RateLimits, err = ParseRateLimits(*c.rateLimitsFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey {
+		level := a.Value.Any().(slog.Level)
+		if level == slog.Level(LevelTrace) {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+func newJSONHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions {
+		Level: level,
+		ReplaceAttr: jsonReplaceAttr,
+	})
+}
+
 func (c *Config) SetupLogger() (l *Logger, closer func() error, err error) {
 	hs := c.Handlers
 
+	if err := c.parseFlags(); err != nil {
+		return nil, nil, err
+	}
+
 	var cs []io.Closer
+	var files []*RotatingFile
+	levels := make(LevelVars)
 
 	if c.HumanWriter == nil && c.humanFileFlag != nil {
 		switch *c.humanFileFlag {
@@ -133,28 +390,37 @@ func (c *Config) SetupLogger() (l *Logger, closer func() error, err error) {
 		case "/dev/stderr":
 			c.HumanWriter = os.Stderr
 		default:
-			f, err := os.OpenFile(*c.humanFileFlag, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			f, err := OpenRotatingFile(*c.humanFileFlag, c.Rotate)
 			if err != nil {
 				return nil, nil, err
 			}
 			c.HumanWriter = f
 			cs = append(cs, f)
+			files = append(files, f)
 		}
 	}
 	if c.HumanWriter != nil {
-		level := c.HumanLevel
+		ml := ModuleLevels { Default: c.HumanLevel, Modules: c.HumanModuleLevels }
 		if c.humanLevelFlag != nil && *c.humanLevelFlag != "" {
-			if level, err = parseLogLevel(*c.humanLevelFlag); err != nil {
+			if ml, err = ParseModuleLevels(*c.humanLevelFlag); err != nil {
 				mkCloser(cs)()
 				return nil, nil, err
 			}
 		}
 
-		hs = append(hs, &HumanHandler {
-			w: c.HumanWriter,
-			Level: level,
-			Fields: c.HumanFields,
-		})
+		lv := NewLevelVar(ml.Default)
+		levels["human"] = lv
+
+		hs = append(hs, withModuleLevels(func(l slog.Leveler) slog.Handler {
+			switch c.HumanFormat {
+			case FormatLogfmt:
+				return NewLogfmtHandler(c.HumanWriter, l, c.HumanFields)
+			case FormatJSON:
+				return newJSONHandler(c.HumanWriter, l)
+			default:
+				return NewHumanHandler(c.HumanWriter, l, c.HumanFields)
+			}
+		}, ml, lv))
 	}
 
 	if c.JsonWriter == nil && c.jsonFileFlag != nil {
@@ -165,66 +431,142 @@ func (c *Config) SetupLogger() (l *Logger, closer func() error, err error) {
 		case "/dev/stderr":
 			c.JsonWriter = os.Stderr
 		default:
-			f, err := os.OpenFile(*c.jsonFileFlag, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			f, err := OpenRotatingFile(*c.jsonFileFlag, c.Rotate)
 			if err != nil {
 				mkCloser(cs)()
 				return nil, nil, err
 			}
 			c.JsonWriter = f
 			cs = append(cs, f)
+			files = append(files, f)
 		}
 	}
 	if c.JsonWriter != nil {
-		level := c.JsonLevel
+		ml := ModuleLevels { Default: c.JsonLevel, Modules: c.JsonModuleLevels }
 		if c.jsonLevelFlag != nil && *c.jsonLevelFlag != "" {
-			if level, err = parseLogLevel(*c.jsonLevelFlag); err != nil {
+			if ml, err = ParseModuleLevels(*c.jsonLevelFlag); err != nil {
 				mkCloser(cs)()
 				return nil, nil, err
 			}
 		}
 
-		opts := slog.HandlerOptions {
-			Level: slog.Level(level),
-			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-				if a.Key == slog.LevelKey {
-					level := a.Value.Any().(slog.Level)
-					if level == slog.Level(LevelTrace) {
-						a.Value = slog.StringValue("TRACE")
-					}
-				}
-				return a
-			},
+		lv := NewLevelVar(ml.Default)
+		levels["json"] = lv
+
+		hs = append(hs, withModuleLevels(func(l slog.Leveler) slog.Handler {
+			return newJSONHandler(c.JsonWriter, l)
+		}, ml, lv))
+	}
+
+	if c.Syslog != "" {
+		ml := ModuleLevels { Default: c.SyslogLevel, Modules: c.SyslogModuleLevels }
+		if c.syslogLevelFlag != nil && *c.syslogLevelFlag != "" {
+			if ml, err = ParseModuleLevels(*c.syslogLevelFlag); err != nil {
+				mkCloser(cs)()
+				return nil, nil, err
+			}
+		}
+
+		w, err := DialSyslog(c.Syslog)
+		if err != nil {
+			mkCloser(cs)()
+			return nil, nil, err
+		}
+		cs = append(cs, w)
+
+		lv := NewLevelVar(ml.Default)
+		levels["syslog"] = lv
+
+		hs = append(hs, withModuleLevels(func(l slog.Leveler) slog.Handler {
+			return NewSyslogHandler(w, l, c.SyslogOptions)
+		}, ml, lv))
+	}
+
+	if c.Journald {
+		ml := ModuleLevels { Default: c.JournaldLevel, Modules: c.JournaldModuleLevels }
+		if c.journaldLevelFlag != nil && *c.journaldLevelFlag != "" {
+			if ml, err = ParseModuleLevels(*c.journaldLevelFlag); err != nil {
+				mkCloser(cs)()
+				return nil, nil, err
+			}
+		}
+
+		w, err := DialJournald("")
+		if err != nil {
+			mkCloser(cs)()
+			return nil, nil, err
+		}
+		cs = append(cs, w)
+
+		lv := NewLevelVar(ml.Default)
+		levels["journald"] = lv
+
+		hs = append(hs, withModuleLevels(func(l slog.Leveler) slog.Handler {
+			return NewJournaldHandler(w, l)
+		}, ml, lv))
+	}
+
+	if c.ReopenOnSIGHUP && len(files) > 0 {
+		// stop reopening before the files are closed
+		cs = append([]io.Closer { reopenOnSIGHUP(files) }, cs...)
+	}
+
+	if c.ControlSocket != "" {
+		srv, err := ServeLevels(c.ControlSocket, levels)
+		if err != nil {
+			mkCloser(cs)()
+			return nil, nil, err
 		}
+		cs = append([]io.Closer { srv }, cs...)
+	}
 
-		hs = append(hs, slog.NewJSONHandler(c.JsonWriter, &opts))
+	if c.LevelSignals {
+		cs = append([]io.Closer { bumpOnSignals(levels) }, cs...)
 	}
 
-	var inner *slog.Logger
+	var h slog.Handler
 	switch len(hs) {
 	case 0:
-		nh := NullHandler{}
-		inner = slog.New(&nh)
+		h = &NullHandler{}
 	case 1:
-		inner = slog.New(hs[0])
+		h = hs[0]
 	default:
 		mh := MultiHandler(hs)
-		inner = slog.New(&mh)
+		h = &mh
+	}
+
+	if c.AsyncQueueSize > 0 && len(hs) > 0 {
+		ah := NewAsyncHandler(h, c.AsyncQueueSize, c.AsyncPolicy)
+		// flush before the files are closed
+		cs = append([]io.Closer { ah }, cs...)
+		h = ah
+	}
+
+	if c.Sampling != nil && len(hs) > 0 {
+		sh := NewSamplingHandler(h, *c.Sampling)
+		// emit the final summary before flushing
+		cs = append([]io.Closer { sh }, cs...)
+		h = sh
 	}
 
+	inner := slog.New(h)
+	closer = mkCloser(cs)
+
 	logger := Logger{
 		inner: inner,
+		levels: levels,
+		closer: closer,
 
 		ExitWriter: c.ExitWriter,
 		ExitLevel: c.ExitLevel,
 	}
 
-	return &logger, mkCloser(cs), nil
+	return &logger, closer, nil
 }
 
 func (c *Config) SetupDefaultLogger() (*Logger, func() error, error) {
 	logger, closer, err := c.SetupLogger()
 	if err != nil {
-		closer()
 		return nil, nil, err
 	}
 
@@ -232,19 +574,24 @@ func (c *Config) SetupDefaultLogger() (*Logger, func() error, error) {
 	return logger, closer, nil
 }
 
+// mkCloser returns a function closing cs once, returning the same error if
+// called again
 func mkCloser(cs []io.Closer) (func() error) {
+	var once sync.Once
+	var err error
 	return func() error {
-		var es []error
-		for _, c := range cs {
-			if err := c.Close(); err != nil {
-				es = append(es, err)
+		once.Do(func() {
+			var es []error
+			for _, c := range cs {
+				if err := c.Close(); err != nil {
+					es = append(es, err)
+				}
 			}
-		}
-		if len(es) > 0 {
-			return fmt.Errorf("multiple errors while closing: %v", es)
-		} else {
-			return nil
-		}
+			if len(es) > 0 {
+				err = fmt.Errorf("multiple errors while closing: %v", es)
+			}
+		})
+		return err
 	}
 }
 
@@ -309,7 +656,56 @@ func (l *Logger) exit(ctx context.Context, code int, msg string, args ...any) {
 		}
 	}
 
-	// TODO close
+	runExitHooks()
+
+	if l != nil {
+		if err := l.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to close logger: %v\n", err)
+		}
+	}
 
 	os.Exit(code)
 }
+
+type exitHook struct {
+	f func()
+}
+
+var (
+	exitHooksMu sync.Mutex
+	exitHooks []*exitHook
+)
+
+// OnExit registers a hook run by Exit and its variants before the logger is
+// closed, e.g. to remove temporary files. The hooks are run in the reverse
+// order of their registration, and the returned function removes the hook.
+func OnExit(f func()) (remove func()) {
+	h := &exitHook { f: f }
+
+	exitHooksMu.Lock()
+	exitHooks = append(exitHooks, h)
+	exitHooksMu.Unlock()
+
+	return func() {
+		exitHooksMu.Lock()
+		defer exitHooksMu.Unlock()
+
+		for i, g := range exitHooks {
+			if g == h {
+				exitHooks = append(exitHooks[:i], exitHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func runExitHooks() {
+	exitHooksMu.Lock()
+	hs := exitHooks
+	exitHooks = nil
+	exitHooksMu.Unlock()
+
+	for i := len(hs) - 1; i >= 0; i-- {
+		hs[i].f()
+	}
+}
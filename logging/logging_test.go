@@ -1,30 +1,38 @@
 package logging
 
 import (
-	"os"
-	"syscall"
-	"testing"
 	"bufio"
-	"reflect"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"testing"
 	"time"
-	"io"
 )
 
-var seed = time.Now().UnixNano()
-var prng = rand.New(rand.NewSource(seed))
+const (
+	childEnv = "LOGGING_TEST_CHILD"
+	seedEnv = "LOGGING_TEST_SEED"
+)
 
-func fork(f func()) (*os.Process, error) {
-	r1, _, err := syscall.Syscall(syscall.SYS_FORK, 0, 0, 0)
-	if r1 < 0 {
-		return nil, err
-	}
-	if r1 == 0 {
-		f()
-		os.Exit(0)
+var seed = func() int64 {
+	if s, ok := os.LookupEnv(seedEnv); ok {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
 	}
+	return time.Now().UnixNano()
+}()
 
-	return os.FindProcess(int(r1))
+// exitCode returns a random exit code, shared with the child processes
+func exitCode() int {
+	// For portability, the status code should be in the range [0, 125].
+	return rand.New(rand.NewSource(seed)).Intn(125+1)
 }
 
 func lines(r io.Reader) (ls []string, err error) {
@@ -40,77 +48,43 @@ func lines(r io.Reader) (ls []string, err error) {
 	return
 }
 
-func run(f func()) (st *os.ProcessState, stdout, stderr []string, err error) {
-	stdoutR, stdoutW, err := os.Pipe()
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	stderrR, stderrW, err := os.Pipe()
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	proc, err := fork(func() {
-		os.Stdout = stdoutW
-		if err := stdoutR.Close(); err != nil {
-			panic(err)
-		}
-
-		os.Stderr = stderrW
-		if err := stderrR.Close(); err != nil {
-			panic(err)
-		}
-
+// run runs f in a child process, by running the test again in the test
+// binary, and returns how it exited and its output
+func run(t *testing.T, f func()) (st *os.ProcessState, stdout, stderr []string, err error) {
+	if os.Getenv(childEnv) == t.Name() {
 		f()
-
-		if err := stdoutW.Close(); err != nil {
-			panic(err)
-		}
-		if err := stderrW.Close(); err != nil {
-			panic(err)
-		}
-	})
-	if err != nil {
-		return nil, nil, nil, err
+		os.Exit(0)
 	}
 
-	if err = stdoutW.Close(); err != nil {
-		return nil, nil, nil, err
-	}
-	if err = stderrW.Close(); err != nil {
-		return nil, nil, nil, err
-	}
+	cmd := exec.Command(os.Args[0], "-test.run=^" + t.Name() + "$")
+	cmd.Env = append(os.Environ(), childEnv + "=" + t.Name(), fmt.Sprintf("%s=%d", seedEnv, seed))
 
-	st, err = proc.Wait()
-	if err != nil {
+	var o, e bytes.Buffer
+	cmd.Stdout = &o
+	cmd.Stderr = &e
+
+	var ee *exec.ExitError
+	if err = cmd.Run(); err != nil && !errors.As(err, &ee) {
 		return nil, nil, nil, err
 	}
 
-	stdout, err = lines(stdoutR)
+	stdout, err = lines(&o)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	if err = stdoutR.Close(); err != nil {
-		return nil, nil, nil, err
-	}
 
-	stderr, err = lines(stderrR)
+	stderr, err = lines(&e)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	if err = stderrR.Close(); err != nil {
-		return nil, nil, nil, err
-	}
 
-	return st, stdout, stderr, nil
+	return cmd.ProcessState, stdout, stderr, nil
 }
 
 func TestExit(t *testing.T) {
-	// For portability, the status code should be in the range [0, 125].
-	ec := prng.Intn(125+1)
+	ec := exitCode()
 
-	st, stdout, stderr, err := run(func() {
+	st, stdout, stderr, err := run(t, func() {
 		cfg := Config {
 			HumanWriter: os.Stdout,
 			HumanFields: HumanHandlerFields {
@@ -137,7 +111,7 @@ func TestExit(t *testing.T) {
 	}
 
 	expectedStdout := []string {
-		"rootmos.io/go-utils/logging.TestExit.func1:logging_test.go:129:ERROR oops!",
+		"rootmos.io/go-utils/logging.TestExit.func1:logging_test.go:103:ERROR oops!",
 	}
 	if !reflect.DeepEqual(stdout, expectedStdout) {
 		t.Fatalf("unexpected stdout: %v != %v", stdout, expectedStdout)
@@ -152,10 +126,9 @@ func TestExit(t *testing.T) {
 }
 
 func TestExitWithNilLogger(t *testing.T) {
-	// For portability, the status code should be in the range [0, 125].
-	ec := prng.Intn(125+1)
+	ec := exitCode()
 
-	st, stdout, stderr, err := run(func() {
+	st, stdout, stderr, err := run(t, func() {
 		var logger *Logger
 		logger.Exitf(ec, "really bad: %d", 7)
 	})
@@ -178,3 +151,45 @@ func TestExitWithNilLogger(t *testing.T) {
 		t.Fatalf("unexpected stderr: %v != %v", stderr, expectedstderr)
 	}
 }
+
+func TestExitClosesLogger(t *testing.T) {
+	ec := exitCode()
+
+	st, stdout, _, err := run(t, func() {
+		cfg := Config {
+			HumanWriter: os.Stdout,
+			HumanFields: HumanHandlerFields { OmitTime: true, OmitPID: true, OmitCaller: true },
+			AsyncQueueSize: 1000,
+		}
+
+		logger, _, err := cfg.SetupLogger()
+		if err != nil {
+			panic(err)
+		}
+
+		OnExit(func() { logger.Info("first hook") })
+		remove := OnExit(func() { logger.Info("removed hook") })
+		OnExit(func() { logger.Info("last hook") })
+		remove()
+
+		for i := 0; i < 100; i++ {
+			logger.Info("queued", "i", i)
+		}
+		logger.Exit(ec, "bye")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.ExitCode() != ec {
+		t.Fatalf("unexpected exit code: %d", st.ExitCode())
+	}
+
+	if len(stdout) != 103 {
+		t.Fatalf("unexpected number of lines: %d", len(stdout))
+	}
+	expected := []string { "INFO queued (i: 99)", "INFO bye", "INFO last hook", "INFO first hook" }
+	if !reflect.DeepEqual(stdout[99:], expected) {
+		t.Fatalf("unexpected stdout: %v != %v", stdout[99:], expected)
+	}
+}
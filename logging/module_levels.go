@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// NameKey is the attribute naming a logger, see Logger.Named
+const NameKey = "logger"
+
+// Named returns a logger whose records' levels are resolved by name by
+// ModuleLevelsHandler, instead of by the caller's package.
+func (l *Logger) Named(name string) *Logger {
+	return l.With(NameKey, name)
+}
+
+// ModuleLevels are the levels of the records of modules, i.e. loggers named
+// or packages matching a key (a package path, a suffix of a package path or
+// a prefix of it, e.g. osext or rootmos.io/go-utils), with the most specific
+// key taking precedence.
+type ModuleLevels struct {
+	Default Level
+	Modules map[string]Level
+}
+
+// ParseModuleLevels parses levels of the form LEVEL[,module=LEVEL...], e.g.
+// INFO,osext=DEBUG,rootmos.io/foo=TRACE.
+func ParseModuleLevels(s string) (ml ModuleLevels, err error) {
+	for i, f := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			if i != 0 {
+				return ml, fmt.Errorf("expected module=LEVEL: %s", f)
+			}
+			if ml.Default, err = ParseLevel(f); err != nil {
+				return ml, err
+			}
+			continue
+		}
+
+		l, err := ParseLevel(v)
+		if err != nil {
+			return ml, err
+		}
+		if ml.Modules == nil {
+			ml.Modules = make(map[string]Level)
+		}
+		ml.Modules[k] = l
+	}
+	return ml, nil
+}
+
+// Min returns the lowest level of any module.
+func (ml *ModuleLevels) Min() Level {
+	return ml.min(ml.Default)
+}
+
+func (ml *ModuleLevels) min(def Level) Level {
+	min := def
+	for _, l := range ml.Modules {
+		if l < min {
+			min = l
+		}
+	}
+	return min
+}
+
+func matchModule(key, module string) bool {
+	return module == key ||
+		strings.HasPrefix(module, key + "/") ||
+		strings.HasSuffix(module, "/" + key) ||
+		strings.Contains(module, "/" + key + "/")
+}
+
+// Level returns the level of the most specific key matching the first of the
+// modules matched by any key.
+func (ml *ModuleLevels) Level(modules ...string) Level {
+	if l, ok := ml.lookup(modules...); ok {
+		return l
+	}
+	return ml.Default
+}
+
+func (ml *ModuleLevels) lookup(modules ...string) (Level, bool) {
+	for _, m := range modules {
+		l, best := ml.Default, -1
+		for k, v := range ml.Modules {
+			if m != "" && len(k) > best && matchModule(k, m) {
+				l, best = v, len(k)
+			}
+		}
+		if best >= 0 {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// packageOf returns the package of a function name as returned by
+// runtime.FuncForPC, e.g. rootmos.io/go-utils/osext for
+// rootmos.io/go-utils/osext.(*Store).Put
+func packageOf(fn string) string {
+	i := strings.LastIndex(fn, "/") + 1
+	if j := strings.Index(fn[i:], "."); j >= 0 {
+		return fn[:i+j]
+	}
+	return fn
+}
+
+// ModuleLevelsHandler filters the records passed to the wrapped handler using
+// the level of the logger's name or, if not matched, the caller's package.
+type ModuleLevelsHandler struct {
+	inner slog.Handler
+	levels *moduleLevels
+	name string
+}
+
+// moduleLevels are module levels with a default level that may change, and
+// whose Level is the lowest of them
+type moduleLevels struct {
+	ModuleLevels
+	def slog.Leveler
+}
+
+func (ml *moduleLevels) Level() slog.Level {
+	return slog.Level(ml.min(Level(ml.def.Level())))
+}
+
+func NewModuleLevelsHandler(inner slog.Handler, levels ModuleLevels) *ModuleLevelsHandler {
+	return &ModuleLevelsHandler {
+		inner: inner,
+		levels: &moduleLevels { ModuleLevels: levels, def: levels.Default },
+	}
+}
+
+// withModuleLevels returns the handler created by mk, filtered by the levels
+// if any modules are given, in which case the handler's level is the lowest
+// level of any module.
+func withModuleLevels(mk func(slog.Leveler) slog.Handler, levels ModuleLevels, def slog.Leveler) slog.Handler {
+	if len(levels.Modules) == 0 {
+		return mk(def)
+	}
+
+	ml := &moduleLevels { ModuleLevels: levels, def: def }
+	return &ModuleLevelsHandler { inner: mk(ml), levels: ml }
+}
+
+func (h *ModuleLevelsHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return lvl >= h.levels.Level() && h.inner.Enabled(ctx, lvl)
+}
+
+func (h *ModuleLevelsHandler) Handle(ctx context.Context, r slog.Record) error {
+	var pkg string
+	if f := runtime.FuncForPC(r.PC); f != nil {
+		pkg = packageOf(f.Name())
+	}
+
+	l, ok := h.levels.lookup(h.name, pkg)
+	if !ok {
+		l = Level(h.levels.def.Level())
+	}
+	if r.Level < slog.Level(l) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *ModuleLevelsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h1 := *h
+	for _, a := range attrs {
+		if a.Key == NameKey && a.Value.Kind() == slog.KindString {
+			h1.name = a.Value.String()
+		}
+	}
+	h1.inner = h.inner.WithAttrs(attrs)
+	return &h1
+}
+
+func (h *ModuleLevelsHandler) WithGroup(name string) slog.Handler {
+	h1 := *h
+	h1.inner = h.inner.WithGroup(name)
+	return &h1
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseModuleLevels(t *testing.T) {
+	ml, err := ParseModuleLevels("INFO,osext=DEBUG,rootmos.io/foo=TRACE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for m, l := range map[string]Level {
+		"rootmos.io/go-utils/osext": LevelDebug,
+		"rootmos.io/go-utils/osext/cas": LevelDebug,
+		"rootmos.io/foo": LevelTrace,
+		"rootmos.io/foo/bar": LevelTrace,
+		"rootmos.io/foobar": LevelInfo,
+		"main": LevelInfo,
+	} {
+		if ml.Level(m) != l {
+			t.Errorf("unexpected level of %s: %v", m, ml.Level(m))
+		}
+	}
+
+	if ml.Min() != LevelTrace {
+		t.Errorf("unexpected min level: %v", ml.Min())
+	}
+
+	if _, err := ParseModuleLevels("INFO,DEBUG"); err == nil {
+		t.Errorf("unexpected success parsing invalid levels")
+	}
+}
+
+func TestModuleLevels(t *testing.T) {
+	render := func(levels string) string {
+		var sb strings.Builder
+		cfg := Config {
+			HumanWriter: &sb,
+			HumanFields: HumanHandlerFields { OmitTime: true, OmitPID: true, OmitCaller: true },
+		}
+		cfg.humanLevelFlag = &levels
+
+		logger, _, err := cfg.SetupLogger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Debug("a")
+		logger.Named("foo").Trace("b")
+		logger.Named("bar").Debug("c")
+		return sb.String()
+	}
+
+	if s := render("INFO,osext=DEBUG"); s != "" {
+		t.Errorf("unexpected output: %q", s)
+	}
+	if s := render("INFO,go-utils/logging=DEBUG,foo=TRACE"); s != "DEBUG a\nTRACE b (logger: foo)\nDEBUG c (logger: bar)\n" {
+		t.Errorf("unexpected output: %q", s)
+	}
+	if s := render("DEBUG,bar=INFO"); s != "DEBUG a\n" {
+		t.Errorf("unexpected output: %q", s)
+	}
+}
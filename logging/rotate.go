@@ -0,0 +1,320 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type RotateOptions struct {
+	// MaxSize rotates the file before it would grow beyond MaxSize bytes
+	MaxSize int64
+	// MaxAge rotates the file when it has been written to for longer than MaxAge
+	MaxAge time.Duration
+	// Keep is the number of rotated files to keep, or all if zero
+	Keep int
+	// Compress gzips the rotated files
+	Compress bool
+}
+
+// RotatingFile appends to a log file which is rotated logrotate style: the
+// current file is renamed to path.1 (or path.1.gz when compressed) after
+// shifting the previously rotated ones one step up.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu sync.Mutex
+	f *os.File
+	size int64
+	opened time.Time
+	// retry delays the next rotation after a failed one
+	retry time.Time
+
+	compressing sync.WaitGroup
+}
+
+// rotateRetry is how long to wait before retrying a failed rotation
+const rotateRetry = time.Minute
+
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	rf := &RotatingFile { path: path, opts: opts }
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.f = f
+	rf.size = fi.Size()
+	rf.opened = time.Now()
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (n int, err error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return 0, fs.ErrClosed
+	}
+
+	// a failed rotation leaves the file open, so keep logging to it
+	if rf.size > 0 && rf.due(int64(len(p))) {
+		if err := rf.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to rotate %s: %v\n", rf.path, err)
+			if rf.f == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err = rf.f.Write(p)
+	rf.size += int64(n)
+	return
+}
+
+func (rf *RotatingFile) due(n int64) bool {
+	if time.Now().Before(rf.retry) {
+		return false
+	}
+	if rf.opts.MaxSize > 0 && rf.size + n > rf.opts.MaxSize {
+		return true
+	}
+	if rf.opts.MaxAge > 0 && time.Since(rf.opened) >= rf.opts.MaxAge {
+		return true
+	}
+	return false
+}
+
+// Rotate rotates the file regardless of its size and age.
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return fs.ErrClosed
+	}
+	return rf.rotate()
+}
+
+// Reopen closes and reopens the file, e.g. after it has been moved away by an
+// external logrotate.
+func (rf *RotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return fs.ErrClosed
+	}
+
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rf.f = nil
+	return rf.open()
+}
+
+// Close closes the file after waiting for any rotated file being compressed.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	rf.compressing.Wait()
+
+	if rf.f == nil {
+		return nil
+	}
+
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
+
+func (rf *RotatingFile) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", rf.path, i)
+}
+
+// existing returns the name of the i:th rotated file, compressed or not
+func (rf *RotatingFile) existing(i int) (string, bool) {
+	for _, p := range []string { rf.rotated(i), rf.rotated(i) + ".gz" } {
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// rotate shifts the rotated files and reopens the file, which is reopened even
+// if the rotation fails. The rotated file is compressed in the background.
+func (rf *RotatingFile) rotate() (err error) {
+	// the previously rotated file may still be being compressed
+	rf.compressing.Wait()
+
+	cerr := rf.f.Close()
+	rf.f = nil
+	defer func() {
+		if err != nil {
+			rf.retry = time.Now().Add(rotateRetry)
+		}
+		if oerr := rf.open(); oerr != nil {
+			err = errors.Join(err, oerr)
+		}
+	}()
+	if cerr != nil {
+		return cerr
+	}
+
+	n := 0
+	for {
+		if _, ok := rf.existing(n + 1); !ok {
+			break
+		}
+		n += 1
+	}
+
+	for i := n; i >= 1; i-- {
+		p, _ := rf.existing(i)
+		if rf.opts.Keep > 0 && i >= rf.opts.Keep {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		q := rf.rotated(i + 1) + strings.TrimPrefix(p, rf.rotated(i))
+		if err := os.Rename(p, q); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(rf.path, rf.rotated(1)); err != nil {
+		return err
+	}
+
+	if rf.opts.Compress {
+		rf.compressing.Add(1)
+		go func(path string) {
+			defer rf.compressing.Done()
+			if err := gzipFile(path); err != nil {
+				fmt.Fprintf(os.Stderr, "unable to compress %s: %v\n", path, err)
+			}
+		}(rf.rotated(1))
+	}
+
+	return nil
+}
+
+func gzipFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path + ".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	w := gzip.NewWriter(dst)
+	if _, err = io.Copy(w, src); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// ParseSize parses a number of bytes optionally suffixed by K, M or G (as
+// powers of 1024).
+func ParseSize(s string) (int64, error) {
+	var mult int64 = 1
+	t := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	t = strings.TrimSuffix(t, "I")
+	switch {
+	case strings.HasSuffix(t, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(t, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(t, "G"):
+		mult = 1 << 30
+	}
+	if mult != 1 {
+		t = t[:len(t)-1]
+	}
+
+	n, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return n * mult, nil
+}
+
+type reopener struct {
+	ch chan os.Signal
+	done chan struct{}
+}
+
+// reopenOnSIGHUP reopens the files whenever SIGHUP is received, until closed.
+func reopenOnSIGHUP(files []*RotatingFile) *reopener {
+	r := &reopener {
+		ch: make(chan os.Signal, 1),
+		done: make(chan struct{}),
+	}
+	signal.Notify(r.ch, syscall.SIGHUP)
+
+	go func() {
+		defer close(r.done)
+		for range r.ch {
+			var es []error
+			for _, f := range files {
+				es = append(es, f.Reopen())
+			}
+			if err := errors.Join(es...); err != nil {
+				fmt.Fprintf(os.Stderr, "unable to reopen log files: %v\n", err)
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *reopener) Close() error {
+	signal.Stop(r.ch)
+	close(r.ch)
+	<-r.done
+	return nil
+}
@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log")
+
+	rf, err := OpenRotatingFile(path, RotateOptions { MaxSize: 10, Keep: 2, Compress: true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for _, l := range []string { "first\n", "second\n", "third\n", "fourth\n" } {
+		if _, err := io.WriteString(rf, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// wait for the compression
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	es, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range es {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, " ") != "log log.1.gz log.2.gz" {
+		t.Fatalf("unexpected files: %v", names)
+	}
+
+	for f, expected := range map[string]string { "log": "fourth\n", "log.1.gz": "third\n", "log.2.gz": "second\n" } {
+		r, err := os.Open(filepath.Join(dir, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		var s io.Reader = r
+		if strings.HasSuffix(f, ".gz") {
+			if s, err = gzip.NewReader(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		bs, err := io.ReadAll(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != expected {
+			t.Errorf("unexpected content of %s: %q", f, bs)
+		}
+	}
+}
+
+func TestRotateHumanLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log")
+
+	rf, err := OpenRotatingFile(path, RotateOptions { MaxSize: 64 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	logger := slog.New(NewHumanHandler(rf, nil, HumanHandlerFields { Color: ColorNever }))
+	const n = 20
+	for i := 0; i < n; i++ {
+		logger.Info("record", "i", i, "padding", "abcdefghijklmnopqrstuvwxyz")
+	}
+
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	es, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) < 2 {
+		t.Fatalf("expected rotated files: %d", len(es))
+	}
+
+	re := regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z:INFO record \(i: [0-9]+\) \(padding: [a-z]{26}\)$`)
+	var lines int
+	for _, e := range es {
+		bs, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasSuffix(bs, []byte("\n")) {
+			t.Errorf("%s ends with an incomplete line: %q", e.Name(), bs)
+		}
+		for _, l := range strings.Split(strings.TrimSuffix(string(bs), "\n"), "\n") {
+			if !re.MatchString(l) {
+				t.Errorf("%s: incomplete line: %q", e.Name(), l)
+			}
+			lines += 1
+		}
+	}
+	if lines != n {
+		t.Errorf("unexpected number of lines: %d != %d", lines, n)
+	}
+}
+
+func TestRotateFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log")
+
+	rf, err := OpenRotatingFile(path, RotateOptions { MaxSize: 10, Keep: 1 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	// a non-empty directory in the way of removing the rotated file
+	if err := os.MkdirAll(filepath.Join(dir, "log.1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(rf, "first\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.Rotate(); err == nil {
+		t.Fatal("unexpected successful rotation")
+	}
+
+	for _, l := range []string { "second\n", "third\n" } {
+		if _, err := io.WriteString(rf, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "first\nsecond\nthird\n" {
+		t.Errorf("unexpected content: %q", bs)
+	}
+
+	// the rotation is retried when no longer obstructed
+	if err := os.RemoveAll(filepath.Join(dir, "log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(rf, "fourth\n"); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(path); err != nil || string(bs) != "fourth\n" {
+		t.Errorf("unexpected content: %q (%v)", bs, err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log")
+
+	rf, err := OpenRotatingFile(path, RotateOptions {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	if _, err := io.WriteString(rf, "before\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path + ".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(rf, "after\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "after\n" {
+		t.Errorf("unexpected content: %q", bs)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64 { "17": 17, "2K": 2048, "3MiB": 3 << 20, "1g": 1 << 30 } {
+		if n, err := ParseSize(s); err != nil || n != expected {
+			t.Errorf("unexpected size of %q: %d (%v)", s, n, err)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Errorf("unexpected success parsing invalid size")
+	}
+}
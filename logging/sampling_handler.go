@@ -0,0 +1,221 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SamplingOptions struct {
+	// Interval is the period the counts are reset and the summary of the
+	// suppressed records is emitted (default 1s)
+	Interval time.Duration
+
+	// First records per interval of the same message and caller are passed,
+	// and then every Thereafter:th (none if zero). Only records of at most
+	// MaxLevel (default DEBUG) are sampled.
+	First int
+	Thereafter int
+	MaxLevel *Level
+
+	// RateLimits limits the number of records per interval of the levels
+	RateLimits map[Level]int
+
+	// SummaryLevel is the level of the summaries
+	SummaryLevel Level
+}
+
+func (o *SamplingOptions) sampling() bool {
+	return o.First > 0 || o.Thereafter > 0
+}
+
+// ParseRateLimits parses limits of the form LEVEL=n[,LEVEL=n...]
+func ParseRateLimits(s string) (map[Level]int, error) {
+	ls := make(map[Level]int)
+	for _, f := range strings.Split(s, ",") {
+		if f == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected LEVEL=n: %s", f)
+		}
+		l, err := ParseLevel(k)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		ls[l] = n
+	}
+	return ls, nil
+}
+
+type samplingKey struct {
+	msg string
+	pc uintptr
+}
+
+type sampler struct {
+	opts SamplingOptions
+	maxLevel slog.Level
+	h slog.Handler
+
+	mu sync.Mutex
+	counts map[samplingKey]int
+	levels map[slog.Level]int
+	suppressed map[slog.Level]int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// SamplingHandler passes a sample of the records to the wrapped handler,
+// keyed by message and caller, and emits a summary of the suppressed records
+// every interval.
+type SamplingHandler struct {
+	inner slog.Handler
+	s *sampler
+}
+
+func NewSamplingHandler(inner slog.Handler, opts SamplingOptions) *SamplingHandler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	s := &sampler {
+		opts: opts,
+		maxLevel: slog.LevelDebug,
+		h: inner,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if opts.MaxLevel != nil {
+		s.maxLevel = slog.Level(*opts.MaxLevel)
+	}
+	s.reset()
+
+	go func() {
+		defer close(s.done)
+
+		t := time.NewTicker(opts.Interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				s.summarize()
+			case <-s.stop:
+				s.summarize()
+				return
+			}
+		}
+	}()
+
+	return &SamplingHandler { inner: inner, s: s }
+}
+
+func (s *sampler) reset() {
+	s.counts = make(map[samplingKey]int)
+	s.levels = make(map[slog.Level]int)
+	s.suppressed = make(map[slog.Level]int)
+}
+
+func (s *sampler) allow(r *slog.Record) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.sampling() && r.Level <= s.maxLevel {
+		k := samplingKey { msg: r.Message, pc: r.PC }
+		n := s.counts[k] + 1
+		s.counts[k] = n
+
+		if n > s.opts.First && (s.opts.Thereafter <= 0 || (n - s.opts.First) % s.opts.Thereafter != 0) {
+			s.suppressed[r.Level] += 1
+			return false
+		}
+	}
+
+	if limit, ok := s.opts.RateLimits[Level(r.Level)]; ok {
+		if s.levels[r.Level] >= limit {
+			s.suppressed[r.Level] += 1
+			return false
+		}
+		s.levels[r.Level] += 1
+	}
+
+	return true
+}
+
+func (s *sampler) summarize() {
+	s.mu.Lock()
+	suppressed := s.suppressed
+	s.reset()
+	s.mu.Unlock()
+
+	var n int
+	var ls []slog.Level
+	for l, m := range suppressed {
+		n += m
+		ls = append(ls, l)
+	}
+	if n == 0 {
+		return
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+
+	r := slog.NewRecord(time.Now(), slog.Level(s.opts.SummaryLevel), fmt.Sprintf("suppressed %d records", n), 0)
+	var as []any
+	for _, l := range ls {
+		k := l.String()
+		if l == slog.Level(LevelTrace) {
+			k = "TRACE"
+		}
+		as = append(as, slog.Int(k, suppressed[l]))
+	}
+	r.AddAttrs(slog.Group("suppressed", as...))
+	r.AddAttrs(slog.Int("pid", os.Getpid()))
+
+	ctx := context.Background()
+	if s.h.Enabled(ctx, r.Level) {
+		_ = s.h.Handle(ctx, r)
+	}
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.inner.Enabled(ctx, lvl)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.s.allow(&r) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler { inner: h.inner.WithAttrs(attrs), s: h.s }
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler { inner: h.inner.WithGroup(name), s: h.s }
+}
+
+// Close stops the periodic summaries after emitting a final one.
+func (h *SamplingHandler) Close() error {
+	h.s.once.Do(func() {
+		close(h.s.stop)
+	})
+	<-h.s.done
+	return nil
+}
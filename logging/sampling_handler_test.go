@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	var sb strings.Builder
+	cfg := Config {
+		HumanWriter: &sb,
+		HumanLevel: LevelTrace,
+		HumanFields: HumanHandlerFields { OmitTime: true, OmitPID: true, OmitCaller: true },
+		Sampling: &SamplingOptions {
+			Interval: time.Hour,
+			First: 2,
+			Thereafter: 3,
+			RateLimits: map[Level]int { LevelTrace: 1 },
+			SummaryLevel: LevelWarn,
+		},
+	}
+
+	logger, closer, err := cfg.SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		logger.With("i", i).Debug("hot")
+		logger.Info("not sampled")
+	}
+	logger.Trace("first")
+	logger.Trace("second")
+
+	if err := closer(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string {
+		"DEBUG hot (i: 0)",
+		"DEBUG hot (i: 1)",
+		"DEBUG hot (i: 4)",
+		"DEBUG hot (i: 7)",
+		"TRACE first",
+		"WARN suppressed 7 records (suppressed: (TRACE: 1) (DEBUG: 6))",
+	}
+	var ls []string
+	for _, l := range strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n") {
+		if l != "INFO not sampled" {
+			ls = append(ls, l)
+		}
+	}
+	if strings.Join(ls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected output:\n%s", sb.String())
+	}
+	if c := strings.Count(sb.String(), "INFO not sampled"); c != 10 {
+		t.Errorf("unexpected number of records not sampled: %d", c)
+	}
+}
+
+func TestSamplingMaxLevel(t *testing.T) {
+	info := LevelInfo
+	for _, c := range []struct {
+		name string
+		maxLevel *Level
+		expected int
+	} {
+		{ name: "default", maxLevel: nil, expected: 5 },
+		{ name: "INFO", maxLevel: &info, expected: 1 },
+	} {
+		var sb strings.Builder
+		cfg := Config {
+			HumanWriter: &sb,
+			HumanFields: HumanHandlerFields { OmitTime: true, OmitPID: true, OmitCaller: true },
+			Sampling: &SamplingOptions {
+				Interval: time.Hour,
+				First: 1,
+				MaxLevel: c.maxLevel,
+			},
+		}
+
+		logger, closer, err := cfg.SetupLogger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			logger.Info("info")
+		}
+		if err := closer(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := strings.Count(sb.String(), "INFO info\n"); n != c.expected {
+			t.Errorf("unexpected number of INFO records with max level %s: %d != %d", c.name, n, c.expected)
+		}
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	ls, err := ParseRateLimits("DEBUG=100,trace=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 2 || ls[LevelDebug] != 100 || ls[LevelTrace] != 10 {
+		t.Errorf("unexpected limits: %v", ls)
+	}
+	if _, err := ParseRateLimits("DEBUG"); err == nil {
+		t.Errorf("unexpected success parsing invalid limits")
+	}
+}
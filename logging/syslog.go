@@ -0,0 +1,336 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Facility int
+
+// The facilities of RFC 5424, except kern which is reserved for the kernel
+const (
+	FacilityUser Facility = iota + 1
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthpriv
+	FacilityFtp
+	FacilityLocal0 Facility = iota + 5
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+var facilities = map[string]Facility {
+	"user": FacilityUser,
+	"mail": FacilityMail,
+	"daemon": FacilityDaemon,
+	"auth": FacilityAuth,
+	"syslog": FacilitySyslog,
+	"lpr": FacilityLpr,
+	"news": FacilityNews,
+	"uucp": FacilityUucp,
+	"cron": FacilityCron,
+	"authpriv": FacilityAuthpriv,
+	"ftp": FacilityFtp,
+	"local0": FacilityLocal0,
+	"local1": FacilityLocal1,
+	"local2": FacilityLocal2,
+	"local3": FacilityLocal3,
+	"local4": FacilityLocal4,
+	"local5": FacilityLocal5,
+	"local6": FacilityLocal6,
+	"local7": FacilityLocal7,
+}
+
+func ParseFacility(s string) (Facility, error) {
+	f, ok := facilities[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility: %s", s)
+	}
+	return f, nil
+}
+
+// syslogSeverity maps a level to the severities used by both syslog and
+// journald, with TRACE being debug as well
+func syslogSeverity(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3
+	case l >= slog.LevelWarn:
+		return 4
+	case l >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// SyslogWriter writes messages to a syslog server, reconnecting if a write
+// fails.
+type SyslogWriter struct {
+	network string
+	addr string
+	// stream is set when messages are framed: by their length over TCP
+	// (RFC 6587 octet counting) and by a newline over Unix stream sockets
+	stream bool
+
+	mu sync.Mutex
+	conn net.Conn
+	closed bool
+}
+
+// DialSyslog connects to a syslog server at an address of the form
+// network://address (unix, unixgram, udp or tcp) or a path of a Unix socket,
+// e.g. /dev/log, unix:///dev/log, udp://localhost:514 or tcp://localhost:514.
+func DialSyslog(addr string) (*SyslogWriter, error) {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok {
+		network, address = "unix", addr
+	}
+
+	switch network {
+	case "unix", "unixgram", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", network)
+	}
+
+	w := &SyslogWriter { network: network, addr: address }
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) dial() (err error) {
+	switch w.network {
+	case "unix":
+		// prefer datagrams, as the traditional /dev/log
+		if w.conn, err = net.Dial("unixgram", w.addr); err == nil {
+			w.stream = false
+			return nil
+		}
+		w.conn, err = net.Dial("unix", w.addr)
+		w.stream = true
+	case "unixgram", "udp", "udp4", "udp6":
+		w.conn, err = net.Dial(w.network, w.addr)
+		w.stream = false
+	default:
+		w.conn, err = net.Dial(w.network, w.addr)
+		w.stream = true
+	}
+	return err
+}
+
+func (w *SyslogWriter) frame(msg []byte) []byte {
+	switch {
+	case !w.stream:
+		return msg
+	case w.network == "unix":
+		return append(msg[:len(msg):len(msg)], '\n')
+	default:
+		return append([]byte(strconv.Itoa(len(msg)) + " "), msg...)
+	}
+}
+
+// Write writes msg as one message.
+func (w *SyslogWriter) Write(msg []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+
+	if w.conn != nil {
+		if _, err := w.conn.Write(w.frame(msg)); err == nil {
+			return len(msg), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.dial(); err != nil {
+		w.conn = nil
+		return 0, err
+	}
+	if _, err := w.conn.Write(w.frame(msg)); err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
+
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+	return nil
+}
+
+// SyslogSDID is the SD-ID of the structured data element carrying the level,
+// caller and attributes (32473 is the enterprise number reserved for
+// documentation by RFC 5612)
+const SyslogSDID = "slog@32473"
+
+type SyslogOptions struct {
+	// Facility is the facility of the messages (default user)
+	Facility Facility
+	// AppName defaults to the name of the executable
+	AppName string
+	// Hostname defaults to the hostname of the machine
+	Hostname string
+}
+
+// SyslogHandler writes records as RFC 5424 messages, one per write, with the
+// attributes as structured data with dotted keys for groups.
+type SyslogHandler struct {
+	w io.Writer
+	Level slog.Leveler
+	Options SyslogOptions
+
+	fields flatFields
+}
+
+func NewSyslogHandler(w io.Writer, level slog.Leveler, opts SyslogOptions) *SyslogHandler {
+	if opts.Facility == 0 {
+		opts.Facility = FacilityUser
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+
+	return &SyslogHandler {
+		w: w,
+		Level: level,
+		Options: opts,
+	}
+}
+
+func (h *SyslogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	min := slog.LevelInfo
+	if h.Level != nil {
+		min = h.Level.Level()
+	}
+	return lvl >= min
+}
+
+func (h0 *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h1 := *h0
+	h1.fields = h0.fields.withAttrs(attrs)
+	return &h1
+}
+
+func (h0 *SyslogHandler) WithGroup(name string) slog.Handler {
+	h1 := *h0
+	h1.fields = h0.fields.withGroup(name)
+	return &h1
+}
+
+// syslogHeader returns s as a header field of at most n printable characters
+func syslogHeader(s string, n int) string {
+	if s == "" {
+		return "-"
+	}
+
+	bs := []byte(s)
+	for i, b := range bs {
+		if b <= ' ' || b > '~' {
+			bs[i] = '_'
+		}
+	}
+	return string(bs[:min(len(bs), n)])
+}
+
+func sdName(s string) string {
+	bs := []byte(s)
+	for i, b := range bs {
+		if b <= ' ' || b > '~' || b == '=' || b == ']' || b == '"' {
+			bs[i] = '_'
+		}
+	}
+	if len(bs) == 0 {
+		return "_"
+	}
+	return string(bs[:min(len(bs), 32)])
+}
+
+var sdValueReplacer = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+func (h *SyslogHandler) Handle(_ context.Context, r slog.Record) error {
+	pid, caller, fs := h.fields.record(r)
+	if pid < 0 {
+		pid = int64(os.Getpid())
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 ", int(h.Options.Facility) * 8 + syslogSeverity(r.Level))
+
+	if r.Time.IsZero() {
+		b.WriteString("-")
+	} else {
+		b.WriteString(r.Time.Format("2006-01-02T15:04:05.000000Z07:00"))
+	}
+
+	fmt.Fprintf(&b, " %s %s %d - ",
+		syslogHeader(h.Options.Hostname, 255),
+		syslogHeader(h.Options.AppName, 48),
+		pid,
+	)
+
+	param := func(k, v string) {
+		fmt.Fprintf(&b, ` %s="%s"`, sdName(k), sdValueReplacer.Replace(v))
+	}
+
+	b.WriteString("[" + SyslogSDID)
+	param("level", Level(r.Level).String())
+	if caller.name != "" {
+		param("caller.name", caller.name)
+	}
+	if caller.file != "" {
+		param("caller.file", caller.file)
+	}
+	if caller.line >= 0 {
+		param("caller.line", strconv.FormatInt(caller.line, 10))
+	}
+	for _, f := range fs {
+		param(f.key, fieldString(f.value))
+	}
+	b.WriteString("]")
+
+	if r.Message != "" {
+		b.WriteString(" " + r.Message)
+	}
+
+	_, err := h.w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestSyslog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr { Name: path, Net: "unixgram" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	cfg := Config {
+		Syslog: path,
+		SyslogLevel: LevelTrace,
+		SyslogOptions: SyslogOptions { Facility: FacilityLocal3, AppName: "test", Hostname: "host" },
+	}
+	logger, closer, err := cfg.SetupLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer()
+
+	logger.WithGroup("req").Trace("hello", "id", `a"b]`, "n", 7)
+	logger.Error("oops")
+
+	expected := []*regexp.Regexp {
+		regexp.MustCompile(`^<159>1 \S+ host test ` + strconv.Itoa(os.Getpid()) + ` - \[slog@32473 level="TRACE" caller.name="rootmos.io/go-utils/logging.TestSyslog" caller.file="\S+/syslog_test.go" caller.line="\d+" req.id="a\\"b\\]" req.n="7"\] hello$`),
+		regexp.MustCompile(`^<155>1 .* - \[slog@32473 level="ERROR" .*\] oops$`),
+	}
+	for _, re := range expected {
+		bs := make([]byte, 1024)
+		n, err := l.Read(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !re.Match(bs[:n]) {
+			t.Errorf("unexpected message: %q", bs[:n])
+		}
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	w, err := DialSyslog("tcp://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, m := range []string { "first", "second message" } {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	for _, m := range []string { "5 first", "14 second message" } {
+		bs := make([]byte, len(m))
+		if _, err := r.Read(bs); err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != m {
+			t.Errorf("unexpected frame: %q != %q", bs, m)
+		}
+	}
+}
+
+func TestParseFacility(t *testing.T) {
+	if f, err := ParseFacility("LOCAL7"); err != nil || f != 23 {
+		t.Errorf("unexpected facility: %d %v", f, err)
+	}
+	if f, err := ParseFacility("daemon"); err != nil || f != 3 {
+		t.Errorf("unexpected facility: %d %v", f, err)
+	}
+	if _, err := ParseFacility("kern"); err == nil {
+		t.Errorf("unexpected success parsing kern")
+	}
+}
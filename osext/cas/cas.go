@@ -0,0 +1,215 @@
+package cas
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"rootmos.io/go-utils/hashed"
+	"rootmos.io/go-utils/logging"
+	"rootmos.io/go-utils/osext"
+)
+
+const (
+	objectsDir = "sha256"
+	tmpDir = "tmp"
+)
+
+// Store keeps content addressed by its SHA256 below an osext URL (e.g. a
+// path, file:// or s3:// URL) laid out as base/sha256/ab/cdef...
+type Store struct {
+	Base string
+
+	CreateOptions *osext.CreateOptions
+}
+
+func New(base string) *Store {
+	return &Store { Base: base }
+}
+
+func (s *Store) Path(digest []byte) string {
+	h := hex.EncodeToString(digest)
+	return osext.Join(s.Base, objectsDir + "/" + h[:2] + "/" + h[2:])
+}
+
+func checkDigest(digest []byte) error {
+	if len(digest) != sha256.Size {
+		return fmt.Errorf("unexpected SHA256 digest length: %d", len(digest))
+	}
+	return nil
+}
+
+func (s *Store) tmpPath() (string, error) {
+	var bs [16]byte
+	if _, err := rand.Read(bs[:]); err != nil {
+		return "", err
+	}
+	return osext.Join(s.Base, tmpDir + "/" + hex.EncodeToString(bs[:])), nil
+}
+
+func (s *Store) createOptions() osext.CreateOptions {
+	var opts osext.CreateOptions
+	if s.CreateOptions != nil {
+		opts = *s.CreateOptions
+	}
+	opts.MakeParents = true
+	return opts
+}
+
+// Put streams r into the store under a temporary name, which is moved into
+// place once its digest is known.
+func (s *Store) Put(ctx context.Context, r io.Reader) ([]byte, error) {
+	logger := logging.Get(ctx)
+
+	tmp, err := s.tmpPath()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := s.createOptions()
+	rh := hashed.ReaderSHA256(r)
+	if err := osext.CreateWithOptions(ctx, tmp, rh, &opts); err != nil {
+		_ = osext.Remove(ctx, tmp)
+		return nil, err
+	}
+
+	digest := rh.Digest()
+	logger, ctx = logging.WithAttrs(ctx, "SHA256", rh.HexDigest())
+
+	ok, err := s.Has(ctx, digest)
+	if err != nil {
+		_ = osext.Remove(ctx, tmp)
+		return nil, err
+	}
+	if ok {
+		logger.Debug("already stored")
+		return digest, osext.Remove(ctx, tmp)
+	}
+
+	if err := osext.Rename(ctx, tmp, s.Path(digest)); err != nil {
+		_ = osext.Remove(ctx, tmp)
+		return nil, err
+	}
+
+	logger.Debug("stored")
+	return digest, nil
+}
+
+type verifyingReadCloser struct {
+	*hashed.VerifyingReader
+	io.Closer
+}
+
+// Get opens the content with the digest, the returned reader fails with
+// hashed.ErrDigestMismatch if the stored content does not match.
+func (s *Store) Get(ctx context.Context, digest []byte) (io.ReadCloser, error) {
+	if err := checkDigest(digest); err != nil {
+		return nil, err
+	}
+
+	rc, err := osext.Open(ctx, s.Path(digest))
+	if err != nil {
+		return nil, err
+	}
+
+	vr, err := hashed.NewVerifyingReaderDigest(rc, hashed.SHA256, digest)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+
+	return &verifyingReadCloser { VerifyingReader: vr, Closer: rc }, nil
+}
+
+func (s *Store) Has(ctx context.Context, digest []byte) (bool, error) {
+	if err := checkDigest(digest); err != nil {
+		return false, err
+	}
+
+	_, err := osext.Stat(ctx, s.Path(digest))
+	if osext.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) Remove(ctx context.Context, digest []byte) error {
+	if err := checkDigest(digest); err != nil {
+		return err
+	}
+	return osext.Remove(ctx, s.Path(digest))
+}
+
+// List returns the digests of the stored content.
+func (s *Store) List(ctx context.Context) ([][]byte, error) {
+	logger := logging.Get(ctx)
+
+	es, err := osext.List(ctx, osext.Join(s.Base, objectsDir))
+	if osext.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ds [][]byte
+	for _, e := range es {
+		d, err := hex.DecodeString(strings.Replace(e.Name, "/", "", 1))
+		if err != nil || len(d) != sha256.Size || !strings.HasPrefix(e.Name, hex.EncodeToString(d)[:2] + "/") {
+			logger.Warn("ignoring unexpected entry", "name", e.Name)
+			continue
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// GC removes all stored content for which keep returns false, and returns the
+// digests removed.
+func (s *Store) GC(ctx context.Context, keep func(digest []byte) bool) ([][]byte, error) {
+	logger := logging.Get(ctx)
+
+	ds, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed [][]byte
+	for _, d := range ds {
+		if keep(d) {
+			continue
+		}
+
+		logger.Debug("removing", "SHA256", hex.EncodeToString(d))
+		if err := s.Remove(ctx, d); err != nil && !osext.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, d)
+	}
+
+	return removed, nil
+}
+
+// RemoveTemporaries removes leftovers of interrupted Puts. Note that this
+// also removes the temporaries of Puts in progress.
+func (s *Store) RemoveTemporaries(ctx context.Context) (int, error) {
+	base := osext.Join(s.Base, tmpDir)
+	es, err := osext.List(ctx, base)
+	if osext.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range es {
+		if err := osext.Remove(ctx, osext.Join(base, e.Name)); err != nil && !osext.IsNotExist(err) {
+			return i, err
+		}
+	}
+	return len(es), nil
+}
@@ -0,0 +1,113 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"rootmos.io/go-utils/hashed"
+	logging "rootmos.io/go-utils/logging/testing"
+	osexttesting "rootmos.io/go-utils/osext/testing"
+)
+
+func testStore(t *testing.T, ctx context.Context, s *Store) {
+	data := []byte("hello")
+	sum := sha256.Sum256(data)
+
+	if ok, err := s.Has(ctx, sum[:]); err != nil || ok {
+		t.Fatalf("unexpected Has before Put: %t %v", ok, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		d, err := s.Put(ctx, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("unable to put: %v", err)
+		}
+		if !bytes.Equal(d, sum[:]) {
+			t.Fatalf("unexpected digest: %x", d)
+		}
+	}
+
+	if ok, err := s.Has(ctx, sum[:]); err != nil || !ok {
+		t.Fatalf("unexpected Has after Put: %t %v", ok, err)
+	}
+
+	r, err := s.Get(ctx, sum[:])
+	if err != nil {
+		t.Fatalf("unable to get: %v", err)
+	}
+	bs, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(bs, data) {
+		t.Fatalf("unexpected content: %q %v", bs, err)
+	}
+
+	other, err := s.Put(ctx, bytes.NewReader([]byte("world")))
+	if err != nil {
+		t.Fatalf("unable to put: %v", err)
+	}
+
+	ds, err := s.List(ctx)
+	if err != nil || len(ds) != 2 {
+		t.Fatalf("unexpected listing: %x %v", ds, err)
+	}
+
+	removed, err := s.GC(ctx, func(d []byte) bool { return bytes.Equal(d, sum[:]) })
+	if err != nil {
+		t.Fatalf("unable to GC: %v", err)
+	}
+	if len(removed) != 1 || !bytes.Equal(removed[0], other) {
+		t.Errorf("unexpected removals: %x", removed)
+	}
+
+	if ok, err := s.Has(ctx, other); err != nil || ok {
+		t.Errorf("unexpected Has after GC: %t %v", ok, err)
+	}
+
+	// a failed Put leaves no temporary behind
+	boom := errors.New("boom")
+	if _, err := s.Put(ctx, io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(boom))); !errors.Is(err, boom) {
+		t.Errorf("unexpected error of failed put: %v", err)
+	}
+
+	if n, err := s.RemoveTemporaries(ctx); err != nil || n != 0 {
+		t.Errorf("unexpected temporaries: %d %v", n, err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	s := New(t.TempDir())
+	testStore(t, ctx, s)
+
+	sum := sha256.Sum256([]byte("hello"))
+	if err := os.WriteFile(filepath.Join(s.Base, "sha256", "2c", "f24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.Get(ctx, sum[:])
+	if err != nil {
+		t.Fatalf("unable to get: %v", err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.Is(err, hashed.ErrDigestMismatch) {
+		t.Errorf("unexpected error reading tampered content: %v", err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+	testStore(t, ctx, New("s3://bucket/cas"))
+
+	keys := srv.Keys("bucket")
+	if len(keys) != 1 || keys[0] != "cas/sha256/2c/f24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
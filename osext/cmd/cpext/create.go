@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"path"
+
+	"rootmos.io/go-utils/logging"
+	"rootmos.io/go-utils/osext"
+)
+
+// tmpPath returns a temporary path next to a local destination, or false if
+// the destination is not a local file
+func tmpPath(dst string) (string, bool, error) {
+	if dst == osext.Stdio {
+		return "", false, nil
+	}
+
+	u, err := url.Parse(dst)
+	if err != nil {
+		return "", false, err
+	}
+	if u.Scheme != "" && u.Scheme != "file" {
+		return "", false, nil
+	}
+
+	var bs [8]byte
+	if _, err := rand.Read(bs[:]); err != nil {
+		return "", false, err
+	}
+	u.Path = path.Join(path.Dir(u.Path), "." + path.Base(u.Path) + ".cpext-" + hex.EncodeToString(bs[:]))
+	return u.String(), true, nil
+}
+
+// create creates a local destination through a temporary file moved into
+// place when complete, so that a failed or interrupted copy leaves neither a
+// partial destination nor the temporary file behind
+func create(ctx context.Context, dst string, r io.Reader, opts *osext.CreateOptions) error {
+	tmp, ok, err := tmpPath(dst)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return osext.CreateWithOptions(ctx, dst, r, opts)
+	}
+
+	remove := logging.OnExit(func() {
+		_ = osext.Remove(ctx, tmp)
+	})
+	defer remove()
+
+	if err := osext.CreateWithOptions(ctx, tmp, r, opts); err != nil {
+		_ = osext.Remove(ctx, tmp)
+		return err
+	}
+
+	if err := osext.Rename(ctx, tmp, dst); err != nil {
+		_ = osext.Remove(ctx, tmp)
+		return err
+	}
+	return nil
+}
@@ -3,24 +3,95 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
+	"rootmos.io/go-utils/hashed"
 	"rootmos.io/go-utils/logging"
 	"rootmos.io/go-utils/osext"
 )
 
 const EnvPrefix = "CPEXT_"
 
+const (
+	ExitFailure = 1
+	ExitUsage = 2
+	ExitNotExist = 3
+	ExitPermission = 4
+	ExitExist = 5
+	ExitUnsupportedScheme = 6
+	// ExitInterrupted is the status shells report for SIGINT
+	ExitInterrupted = 130
+)
+
+func exitCode(err error) int {
+	switch {
+	case osext.IsNotExist(err):
+		return ExitNotExist
+	case osext.IsPermission(err):
+		return ExitPermission
+	case osext.IsExist(err):
+		return ExitExist
+	case errors.Is(err, osext.ErrUnsupportedScheme):
+		return ExitUnsupportedScheme
+	default:
+		return ExitFailure
+	}
+}
+
+type keyValues map[string]string
+
+func (kvs *keyValues) String() string {
+	var ss []string
+	for k, v := range *kvs {
+		ss = append(ss, k + "=" + v)
+	}
+	return strings.Join(ss, ",")
+}
+
+func (kvs *keyValues) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value: %s", s)
+	}
+	if *kvs == nil {
+		*kvs = make(keyValues)
+	}
+	(*kvs)[k] = v
+	return nil
+}
+
 func init() {
 	logging.DefaultHumanLevel = "WARN"
 }
 
 func main() {
 	verbose := flag.Bool("v", false, "print actions taken to stderr")
+
+	var createOpts osext.CreateOptions
+	flag.StringVar(&createOpts.ContentType, "content-type", "", "set Content-Type (guessed from the destination's extension if not set)")
+	flag.BoolVar(&createOpts.NoGuessContentType, "no-guess-content-type", false, "do not guess Content-Type")
+	flag.StringVar(&createOpts.CacheControl, "cache-control", "", "set Cache-Control")
+	var metadata, tags keyValues
+	flag.Var(&metadata, "metadata", "set user metadata (key=value, may be repeated)")
+	flag.Var(&tags, "tag", "set object tag (key=value, may be repeated)")
+	flag.StringVar(&createOpts.StorageClass, "storage-class", "", "set storage class (e.g. STANDARD_IA, GLACIER_IR)")
+	flag.StringVar(&createOpts.ServerSideEncryption, "sse", "", "set server-side encryption (AES256 or aws:kms)")
+	flag.StringVar(&createOpts.SSEKMSKeyId, "sse-kms-key-id", "", "set KMS key used for server-side encryption (implies -sse=aws:kms)")
+	flag.StringVar(&createOpts.SSEKMSEncryptionContext, "sse-kms-context", "", "set KMS encryption context (base64 encoded JSON)")
+	flag.BoolVar(&createOpts.BucketKeyEnabled, "sse-bucket-key", false, "use an S3 Bucket Key for SSE-KMS")
+
+	var m manifest
+	flag.StringVar(&m.path, "manifest", "", "write a sha256sum manifest of the copied files")
+	manifestTag := flag.Bool("manifest-tag", false, "write a BSD style manifest")
+
 	logConfig := logging.PrepareConfig(EnvPrefix)
 	flag.Parse()
 
@@ -31,11 +102,37 @@ func main() {
 	defer closer()
 	logger.Debug("hello")
 
-	if flag.NArg() != 2 {
-		logger.Exitf(2, "%s expects two args (%d given): source and destination", filepath.Base(os.Args[0]), flag.NArg())
+	ctx := logging.Set(context.Background(), logger)
+
+	// exit through the logger to run the exit hooks removing temporary files
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		logger.Exitf(ExitInterrupted, "interrupted: %s", sig)
+	}()
+
+	createOpts.Metadata = metadata
+	createOpts.Tags = tags
+
+	if *manifestTag {
+		m.format = hashed.ManifestBSD
 	}
 
-	ctx := logging.Set(context.Background(), logger)
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "sync":
+			runSync(ctx, flag.Args()[1:], &createOpts, *verbose, &m)
+			return
+		case "check":
+			runCheck(ctx, flag.Args()[1:])
+			return
+		}
+	}
+
+	if flag.NArg() != 2 {
+		logger.Exitf(ExitUsage, "%s expects two args (%d given): source and destination", filepath.Base(os.Args[0]), flag.NArg())
+	}
 
 	src := flag.Args()[0]
 	dst := flag.Args()[1]
@@ -43,21 +140,19 @@ func main() {
 
 	r, err := osext.Open(ctx, src)
 	if err != nil {
-		if osext.IsNotExist(err) {
-			logger.Exitf(1, "unable to open source: %s", err)
-		} else {
-			logger.Exitf(1, "unexpected error while opening source: %s", err)
-		}
+		logger.Exitf(exitCode(err), "unable to open source: %s", err)
 	}
 	defer r.Close()
 
-	err = osext.Create(ctx, dst, r)
+	rh := hashed.ReaderSHA256(r)
+	err = create(ctx, dst, rh, &createOpts)
 	if err != nil {
-		if osext.IsNotExist(err) {
-			logger.Exitf(1, "unable to create destination: %s", err)
-		} else {
-			logger.Exitf(1, "unexpected error while creating destination: %s", err)
-		}
+		logger.Exitf(exitCode(err), "unable to create destination: %s", err)
+	}
+
+	m.add(dst, rh.Digest())
+	if err := m.write(ctx); err != nil {
+		logger.Exitf(exitCode(err), "unable to write manifest: %s", err)
 	}
 
 	if *verbose {
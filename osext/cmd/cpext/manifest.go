@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"rootmos.io/go-utils/hashed"
+	"rootmos.io/go-utils/logging"
+	"rootmos.io/go-utils/osext"
+)
+
+type manifest struct {
+	path string
+	format hashed.ManifestFormat
+	entries hashed.Manifest
+}
+
+func (m *manifest) add(path string, digest []byte) {
+	if m.path == "" {
+		return
+	}
+
+	m.entries = append(m.entries, hashed.ManifestEntry {
+		Algorithm: hashed.SHA256,
+		Digest: digest,
+		Path: path,
+	})
+}
+
+func (m *manifest) write(ctx context.Context) error {
+	if m.path == "" {
+		return nil
+	}
+
+	return osext.WriteManifest(ctx, m.path, m.entries, m.format)
+}
+
+func runCheck(ctx context.Context, args []string) {
+	logger := logging.Get(ctx)
+
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	base := fs.String("base", "", "resolve relative paths against this directory or URL")
+	quiet := fs.Bool("quiet", false, "do not print OK for each successfully verified path")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		logger.Exitf(ExitUsage, "check expects one arg (%d given): manifest", fs.NArg())
+	}
+
+	m, err := osext.ReadManifest(ctx, fs.Arg(0), "")
+	if err != nil {
+		logger.Exitf(exitCode(err), "unable to read manifest: %s", err)
+	}
+
+	var failed int
+	for _, r := range osext.CheckManifest(ctx, m, *base) {
+		switch r.Status {
+		case hashed.CheckOK:
+			if !*quiet {
+				fmt.Printf("%s: %s\n", r.Path, r.Status)
+			}
+			continue
+		case hashed.CheckMismatch:
+			fmt.Printf("%s: %s\n", r.Path, r.Status)
+		default:
+			fmt.Printf("%s: %s (%s)\n", r.Path, r.Status, r.Err)
+		}
+		failed += 1
+	}
+
+	if failed > 0 {
+		logger.Exitf(ExitFailure, "%d of %d listed paths failed verification", failed, len(m))
+	}
+}
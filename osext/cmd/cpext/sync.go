@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"rootmos.io/go-utils/hashed"
+	"rootmos.io/go-utils/logging"
+	"rootmos.io/go-utils/osext"
+)
+
+type syncSummary struct {
+	created int
+	updated int
+	unchanged int
+	deleted int
+}
+
+func differs(ctx context.Context, src, dst string, s, d osext.Entry) (bool, error) {
+	if s.Size != d.Size {
+		return true, nil
+	}
+
+	sh, err := osext.SHA256(ctx, src)
+	if err != nil {
+		return false, err
+	}
+
+	dh, err := osext.SHA256(ctx, dst)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(sh, dh), nil
+}
+
+func copyEntry(ctx context.Context, src, dst string, createOpts *osext.CreateOptions) ([]byte, error) {
+	r, err := osext.Open(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	rh := hashed.ReaderSHA256(r)
+	if err := create(ctx, dst, rh, createOpts); err != nil {
+		return nil, err
+	}
+	return rh.Digest(), nil
+}
+
+type syncOptions struct {
+	create osext.CreateOptions
+	delete bool
+	dryRun bool
+	verbose bool
+}
+
+// syncDirs copies the entries of src missing or changed in dst, printing the
+// actions taken to out.
+func syncDirs(ctx context.Context, src, dst string, opts *syncOptions, out io.Writer, m *manifest) (summary syncSummary, err error) {
+	logger := logging.Get(ctx)
+
+	srcs, err := osext.List(ctx, src)
+	if err != nil {
+		return summary, fmt.Errorf("unable to list source: %w", err)
+	}
+
+	dsts, err := osext.List(ctx, dst)
+	if err != nil && !osext.IsNotExist(err) {
+		return summary, fmt.Errorf("unable to list destination: %w", err)
+	}
+	extra := make(map[string]osext.Entry)
+	for _, e := range dsts {
+		extra[e.Name] = e
+	}
+
+	createOpts := opts.create
+	createOpts.MakeParents = true
+
+	for _, s := range srcs {
+		srcUrl := osext.Join(src, s.Name)
+		dstUrl := osext.Join(dst, s.Name)
+
+		action := "create"
+		if d, ok := extra[s.Name]; ok {
+			delete(extra, s.Name)
+
+			changed, err := differs(ctx, srcUrl, dstUrl, s, d)
+			if err != nil {
+				return summary, fmt.Errorf("unable to compare %s and %s: %w", srcUrl, dstUrl, err)
+			}
+			if !changed {
+				logger.Debug("unchanged", "name", s.Name)
+				summary.unchanged += 1
+				continue
+			}
+			action = "update"
+		}
+
+		fmt.Fprintf(out, "%s %s\n", action, s.Name)
+		if !opts.dryRun {
+			digest, err := copyEntry(ctx, srcUrl, dstUrl, &createOpts)
+			if err != nil {
+				return summary, fmt.Errorf("unable to copy %s to %s: %w", srcUrl, dstUrl, err)
+			}
+			m.add(dstUrl, digest)
+			if opts.verbose {
+				fmt.Fprintf(os.Stderr, "%s -> %s\n", srcUrl, dstUrl)
+			}
+		}
+
+		if action == "create" {
+			summary.created += 1
+		} else {
+			summary.updated += 1
+		}
+	}
+
+	if opts.delete {
+		for _, d := range dsts {
+			if _, ok := extra[d.Name]; !ok {
+				continue
+			}
+
+			dstUrl := osext.Join(dst, d.Name)
+			fmt.Fprintf(out, "delete %s\n", d.Name)
+			if !opts.dryRun {
+				if err := osext.Remove(ctx, dstUrl); err != nil {
+					return summary, fmt.Errorf("unable to delete %s: %w", dstUrl, err)
+				}
+				if opts.verbose {
+					fmt.Fprintf(os.Stderr, "rm %s\n", dstUrl)
+				}
+			}
+			summary.deleted += 1
+		}
+	}
+
+	if !opts.dryRun {
+		if err := m.write(ctx); err != nil {
+			return summary, fmt.Errorf("unable to write manifest: %w", err)
+		}
+	}
+
+	return summary, nil
+}
+
+func runSync(ctx context.Context, args []string, createOpts *osext.CreateOptions, verbose bool, m *manifest) {
+	logger := logging.Get(ctx)
+
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	del := fs.Bool("delete", false, "delete destination entries not present in the source")
+	dryRun := fs.Bool("dry-run", false, "print the actions that would be taken without taking them")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		logger.Exitf(ExitUsage, "sync expects two args (%d given): source and destination", fs.NArg())
+	}
+
+	src := fs.Arg(0)
+	dst := fs.Arg(1)
+	logger.Infof("sync %s -> %s", src, dst)
+
+	opts := syncOptions {
+		create: *createOpts,
+		delete: *del,
+		dryRun: *dryRun,
+		verbose: verbose,
+	}
+	summary, err := syncDirs(ctx, src, dst, &opts, os.Stdout, m)
+	if err != nil {
+		logger.Exitf(exitCode(err), "%s", err)
+	}
+
+	var suffix string
+	if *dryRun {
+		suffix = " (dry run)"
+	}
+	fmt.Printf("created: %d, updated: %d, unchanged: %d, deleted: %d%s\n",
+		summary.created, summary.updated, summary.unchanged, summary.deleted, suffix)
+}
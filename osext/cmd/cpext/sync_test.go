@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	logging "rootmos.io/go-utils/logging/testing"
+	osexttesting "rootmos.io/go-utils/osext/testing"
+)
+
+func TestSync(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	src := t.TempDir()
+	dst := "s3://bucket/prefix"
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectObjects := func(expected map[string]string) {
+		t.Helper()
+		var keys []string
+		for k := range expected {
+			keys = append(keys, "prefix/" + k)
+		}
+		slices.Sort(keys)
+		if actual := srv.Keys("bucket"); !slices.Equal(actual, keys) {
+			t.Fatalf("unexpected keys: %q != %q", actual, keys)
+		}
+		for k, v := range expected {
+			o, ok := srv.Object("bucket", "prefix/" + k)
+			if !ok {
+				t.Fatalf("missing object: %s", k)
+			}
+			if string(o.Data) != v {
+				t.Errorf("unexpected content of %s: %q != %q", k, o.Data, v)
+			}
+		}
+	}
+
+	sync := func(opts syncOptions, expected syncSummary, output string) {
+		t.Helper()
+		var out bytes.Buffer
+		summary, err := syncDirs(ctx, src, dst, &opts, &out, &manifest{})
+		if err != nil {
+			t.Fatalf("unable to sync: %v", err)
+		}
+		if summary != expected {
+			t.Errorf("unexpected summary: %+v != %+v", summary, expected)
+		}
+		if out.String() != output {
+			t.Errorf("unexpected output: %q != %q", out.String(), output)
+		}
+	}
+
+	write("a", "hello")
+	write("b", "world")
+	write("c", "same")
+
+	sync(syncOptions { dryRun: true }, syncSummary { created: 3 }, "create a\ncreate b\ncreate c\n")
+	expectObjects(map[string]string {})
+
+	sync(syncOptions {}, syncSummary { created: 3 }, "create a\ncreate b\ncreate c\n")
+	expectObjects(map[string]string { "a": "hello", "b": "world", "c": "same" })
+
+	sync(syncOptions {}, syncSummary { unchanged: 3 }, "")
+
+	// a changes size, b keeps its size but changes its content
+	write("a", "hello, world")
+	write("b", "WORLD")
+	if err := os.Remove(filepath.Join(src, "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	sync(syncOptions { delete: true, dryRun: true }, syncSummary { updated: 2, deleted: 1 }, "update a\nupdate b\ndelete c\n")
+	expectObjects(map[string]string { "a": "hello", "b": "world", "c": "same" })
+
+	sync(syncOptions {}, syncSummary { updated: 2 }, "update a\nupdate b\n")
+	expectObjects(map[string]string { "a": "hello, world", "b": "WORLD", "c": "same" })
+
+	sync(syncOptions { delete: true }, syncSummary { unchanged: 2, deleted: 1 }, "delete c\n")
+	expectObjects(map[string]string { "a": "hello, world", "b": "WORLD" })
+}
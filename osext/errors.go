@@ -0,0 +1,131 @@
+package osext
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported URL scheme")
+	ErrPermission = fs.ErrPermission
+	ErrAlreadyExists = fs.ErrExist
+)
+
+type URLError struct {
+	Op string
+	URL string
+	Err error
+}
+
+func (e *URLError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.URL, e.Err)
+}
+
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+type HTTPError struct {
+	StatusCode int
+	Status string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status: %s", e.Status)
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case fs.ErrPermission:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case fs.ErrExist:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
+
+func wrapError(op, rawUrl string, err *error) {
+	if *err == nil {
+		return
+	}
+
+	var ue *URLError
+	if errors.As(*err, &ue) {
+		return
+	}
+
+	// the URL already identifies the path
+	var pe *fs.PathError
+	if errors.As(*err, &pe) {
+		*err = &URLError { Op: op, URL: rawUrl, Err: pe.Err }
+		return
+	}
+
+	*err = &URLError { Op: op, URL: rawUrl, Err: *err }
+}
+
+func s3ErrorCode(err error) string {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		return apiError.ErrorCode()
+	}
+	return ""
+}
+
+func httpStatusCode(err error) int {
+	var re interface{ HTTPStatusCode() int }
+	if errors.As(err, &re) {
+		return re.HTTPStatusCode()
+	}
+	return 0
+}
+
+func IsNotExist(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+
+	switch s3ErrorCode(err) {
+	case "NoSuchKey", "NoSuchBucket", "NotFound":
+		return true
+	}
+
+	return httpStatusCode(err) == http.StatusNotFound
+}
+
+func IsPermission(err error) bool {
+	if errors.Is(err, ErrPermission) {
+		return true
+	}
+
+	switch s3ErrorCode(err) {
+	case "AccessDenied", "AllAccessDisabled", "Forbidden":
+		return true
+	}
+
+	switch httpStatusCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+
+	return false
+}
+
+func IsExist(err error) bool {
+	if errors.Is(err, ErrAlreadyExists) {
+		return true
+	}
+
+	switch s3ErrorCode(err) {
+	case "BucketAlreadyExists", "BucketAlreadyOwnedByYou", "PreconditionFailed":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package osext
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rootmos.io/go-utils/logging"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type Entry struct {
+	// Name is the slash separated path of the entry relative to the listed URL
+	Name string
+	Size int64
+}
+
+func Join(rawUrl string, name string) string {
+	if name == "" {
+		return rawUrl
+	}
+	return strings.TrimRight(rawUrl, "/") + "/" + name
+}
+
+// List the regular files below a directory or the objects below an S3 prefix,
+// sorted by name.
+func List(ctx context.Context, rawUrl string) (es []Entry, err error) {
+	defer wrapError("list", rawUrl, &err)
+	logger := logging.Get(ctx)
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "s3":
+		s3c, err := getS3(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		bucket, prefix := bucketKeyFromUrl(u)
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		logger, ctx = logging.WithAttrs(ctx, "bucket", bucket, "prefix", prefix)
+
+		logger.Debug("list objects")
+		p := s3.NewListObjectsV2Paginator(s3c, &s3.ListObjectsV2Input {
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(prefix),
+		})
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range page.Contents {
+				es = append(es, Entry {
+					Name: strings.TrimPrefix(aws.ToString(o.Key), prefix),
+					Size: aws.ToInt64(o.Size),
+				})
+			}
+		}
+	case "", "file":
+		root := filepath.Join(u.Host, u.Path)
+
+		logger, _ = logging.WithAttrs(ctx, "path", root)
+
+		logger.Debug("walk")
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			i, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				rel = ""
+			}
+
+			es = append(es, Entry {
+				Name: filepath.ToSlash(rel),
+				Size: i.Size(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedScheme
+	}
+
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].Name < es[j].Name
+	})
+
+	logger.Debug("listed", "entries", len(es))
+	return es, nil
+}
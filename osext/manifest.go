@@ -0,0 +1,45 @@
+package osext
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"rootmos.io/go-utils/hashed"
+)
+
+func isAbsolute(p string) bool {
+	return strings.HasPrefix(p, "/") || strings.Contains(p, "://")
+}
+
+// CheckManifest verifies every path listed in the manifest, resolving
+// relative paths against base unless empty.
+func CheckManifest(ctx context.Context, m hashed.Manifest, base string) []hashed.CheckResult {
+	return m.Check(func(p string) (io.ReadCloser, error) {
+		if base != "" && !isAbsolute(p) {
+			p = Join(base, p)
+		}
+		return Open(ctx, p)
+	}, IsNotExist)
+}
+
+func ReadManifest(ctx context.Context, rawUrl string, alg hashed.Algorithm) (hashed.Manifest, error) {
+	r, err := Open(ctx, rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return hashed.ReadManifest(r, alg)
+}
+
+func WriteManifest(ctx context.Context, rawUrl string, m hashed.Manifest, f hashed.ManifestFormat) error {
+	var sb strings.Builder
+	if err := hashed.WriteManifest(&sb, m, f); err != nil {
+		return err
+	}
+
+	return CreateWithOptions(ctx, rawUrl, strings.NewReader(sb.String()), &CreateOptions {
+		ContentType: "text/plain",
+	})
+}
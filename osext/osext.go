@@ -1,14 +1,15 @@
 package osext
 
 import (
-	"bytes"
 	"context"
-	"errors"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -17,14 +18,27 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/aws/smithy-go"
 )
 
+const S3ClientKey = "s3client"
+
+// Stdio is the URL denoting stdin when opened and stdout when created.
+const Stdio = "-"
+
 var s3Client *s3.Client
 
+func SetS3Client(ctx context.Context, c *s3.Client) context.Context {
+	return context.WithValue(ctx, S3ClientKey, c)
+}
+
 func getS3(ctx context.Context) (*s3.Client, error) {
+	if c, ok := ctx.Value(S3ClientKey).(*s3.Client); ok {
+		return c, nil
+	}
+
 	if s3Client != nil {
 		return s3Client, nil
 	}
@@ -46,9 +60,120 @@ func bucketKeyFromUrl(u *url.URL) (bucket, key string) {
 	return
 }
 
+type CreateOptions struct {
+	ContentType string
+	NoGuessContentType bool
+	CacheControl string
+	Metadata map[string]string
+	StorageClass string
+	ServerSideEncryption string
+	SSEKMSKeyId string
+	SSEKMSEncryptionContext string
+	BucketKeyEnabled bool
+	Tags map[string]string
+
+	// MakeParents creates missing parent directories of file destinations
+	MakeParents bool
+}
+
+func (o *CreateOptions) contentType(key string) string {
+	if o.ContentType != "" || o.NoGuessContentType {
+		return o.ContentType
+	}
+	return mime.TypeByExtension(path.Ext(key))
+}
+
+func (o *CreateOptions) tagging() string {
+	if len(o.Tags) == 0 {
+		return ""
+	}
+	vs := url.Values{}
+	for k, v := range o.Tags {
+		vs.Set(k, v)
+	}
+	return vs.Encode()
+}
+
+func (o *CreateOptions) applyPutObject(i *s3.PutObjectInput) {
+	if ct := o.contentType(*i.Key); ct != "" {
+		i.ContentType = aws.String(ct)
+	}
+	if o.CacheControl != "" {
+		i.CacheControl = aws.String(o.CacheControl)
+	}
+	if len(o.Metadata) > 0 {
+		i.Metadata = o.Metadata
+	}
+	if o.StorageClass != "" {
+		i.StorageClass = types.StorageClass(o.StorageClass)
+	}
+
+	sse := o.ServerSideEncryption
+	if sse == "" && o.SSEKMSKeyId != "" {
+		sse = string(types.ServerSideEncryptionAwsKms)
+	}
+	if sse != "" {
+		i.ServerSideEncryption = types.ServerSideEncryption(sse)
+	}
+	if o.SSEKMSKeyId != "" {
+		i.SSEKMSKeyId = aws.String(o.SSEKMSKeyId)
+	}
+	if o.SSEKMSEncryptionContext != "" {
+		i.SSEKMSEncryptionContext = aws.String(o.SSEKMSEncryptionContext)
+	}
+	if o.BucketKeyEnabled {
+		i.BucketKeyEnabled = aws.Bool(true)
+	}
+
+	if t := o.tagging(); t != "" {
+		i.Tagging = aws.String(t)
+	}
+}
+
+// seekable returns r and its offset if it can seek, e.g. a regular file but
+// not a pipe
+func seekable(r io.Reader) (io.ReadSeeker, int64, bool) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return nil, 0, false
+	}
+	off, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, false
+	}
+	return rs, off, true
+}
+
+func writeHashed(logger *logging.Logger, w io.Writer, r io.Reader) error {
+	wh := hashed.WriterSHA256(w)
+
+	if _, err := io.Copy(wh, r); err != nil {
+		return err
+	}
+
+	logger.Debug("wrote", "bytes", wh.Size(), "SHA256", wh.HexDigest())
+	return nil
+}
+
 func Create(ctx context.Context, rawUrl string, r io.Reader) error {
+	return CreateWithOptions(ctx, rawUrl, r, nil)
+}
+
+// CreateWithOptions creates rawUrl as Create, with the metadata of opts (if
+// not nil) applied to S3 objects.
+func CreateWithOptions(ctx context.Context, rawUrl string, r io.Reader, opts *CreateOptions) (err error) {
+	defer wrapError("create", rawUrl, &err)
 	logger := logging.Get(ctx)
 
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+
+	if rawUrl == Stdio {
+		logger.Debug("writing to stdout")
+		return writeHashed(logger, os.Stdout, r)
+	}
+
 	u, err := url.Parse(rawUrl)
 	if err != nil {
 		return err
@@ -64,29 +189,59 @@ func Create(ctx context.Context, rawUrl string, r io.Reader) error {
 		bucket, key := bucketKeyFromUrl(u)
 		logger, ctx = logging.WithAttrs(ctx, "bucket", bucket, "key", key)
 
-		var buf bytes.Buffer
-		rh := hashed.ReaderSHA256(r)
-		n, err := io.Copy(&buf, rh)
-
-		logger.Debug("putting object", "bytes", n, "SHA256", rh.HexDigest())
-		o, err := s3c.PutObject(ctx, &s3.PutObjectInput {
+		i := &s3.PutObjectInput {
 			Bucket: aws.String(bucket),
 			Key: aws.String(key),
-			Body: &buf,
-			ChecksumSHA256: aws.String(rh.B64Digest()),
-		})
+		}
+		opts.applyPutObject(i)
+
+		if rs, off, ok := seekable(r); ok {
+			// hash and rewind, so that the checksum of the whole object is
+			// verified by S3
+			rh := hashed.ReaderSHA256(rs)
+			if _, err := io.Copy(io.Discard, rh); err != nil {
+				return err
+			}
+			if _, err := rs.Seek(off, io.SeekStart); err != nil {
+				return err
+			}
+			i.Body = rs
+			i.ChecksumSHA256 = aws.String(rh.B64Digest())
+
+			logger.Debug("putting object", "bytes", rh.Size(), "SHA256", rh.HexDigest(), "ContentType", aws.ToString(i.ContentType))
+			o, err := s3c.PutObject(ctx, i)
+			if err == nil {
+				logger.Debug("put object", "VersionId", aws.ToString(o.VersionId), "SHA256", rh.B64Digest())
+			}
+			return err
+		}
+
+		// streams, e.g. stdin, are uploaded in parts so that only a few parts
+		// are held in memory, each part checksummed
+		rh := hashed.ReaderSHA256(r)
+		i.Body = rh
+		i.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+
+		logger.Debug("uploading stream", "ContentType", aws.ToString(i.ContentType))
+		o, err := manager.NewUploader(s3c).Upload(ctx, i)
 		if err == nil {
-			logger.Debug("put object", "VersionId", aws.ToString(o.VersionId), "SHA256", rh.B64Digest())
+			logger.Debug("uploaded stream", "bytes", rh.Size(), "SHA256", rh.HexDigest(), "parts", len(o.CompletedParts), "VersionId", aws.ToString(o.VersionID))
 		}
 		return err
 	case "http", "https":
 		// TODO
-		return fmt.Errorf("unimplemented URL scheme: %s", u.Scheme)
+		return ErrUnsupportedScheme
 	case "", "file":
 		path := filepath.Join(u.Host, u.Path)
 
 		logger, _ = logging.WithAttrs(ctx, "path", path)
 
+		if opts.MakeParents {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+		}
+
 		logger.Debug("create")
 		f, err := os.Create(path)
 		if err != nil {
@@ -94,40 +249,21 @@ func Create(ctx context.Context, rawUrl string, r io.Reader) error {
 		}
 		defer f.Close()
 
-		wh := hashed.WriterSHA256(f)
-
-		n, err := io.Copy(wh, r)
-		if err != nil {
-			return err
-		}
-		if err == nil {
-			logger.Debug("wrote", "bytes", n, "SHA256", wh.HexDigest())
-		}
-		return err
+		return writeHashed(logger, f, r)
 	default:
-		return fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+		return ErrUnsupportedScheme
 	}
 }
 
-func IsNotExist(err error) bool {
-	if os.IsNotExist(err) {
-		return true
-	}
+func Open(ctx context.Context, rawUrl string) (rc io.ReadCloser, err error) {
+	defer wrapError("open", rawUrl, &err)
+	logger := logging.Get(ctx)
 
-	var apiError smithy.APIError
-	if errors.As(err, &apiError) {
-		switch apiError.(type) {
-		case *types.NoSuchKey:
-			return true
-		}
+	if rawUrl == Stdio {
+		logger.Debug("reading from stdin")
+		return io.NopCloser(os.Stdin), nil
 	}
 
-	return false
-}
-
-func Open(ctx context.Context, rawUrl string) (io.ReadCloser, error) {
-	logger := logging.Get(ctx)
-
 	u, err := url.Parse(rawUrl)
 	if err != nil {
 		return nil, err
@@ -161,7 +297,11 @@ func Open(ctx context.Context, rawUrl string) (io.ReadCloser, error) {
 		if err != nil {
 			return nil, err
 		}
-		return rsp.Body, err
+		if rsp.StatusCode >= 400 {
+			rsp.Body.Close()
+			return nil, &HTTPError { StatusCode: rsp.StatusCode, Status: rsp.Status }
+		}
+		return rsp.Body, nil
 	case "", "file":
 		path := filepath.Join(u.Host, u.Path)
 
@@ -170,6 +310,209 @@ func Open(ctx context.Context, rawUrl string) (io.ReadCloser, error) {
 		logger.Debug("open")
 		return os.Open(path)
 	default:
-		return nil, fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+		return nil, ErrUnsupportedScheme
+	}
+}
+
+func hashSHA256(r io.Reader) ([]byte, error) {
+	rh := hashed.ReaderSHA256(r)
+	if _, err := io.Copy(io.Discard, rh); err != nil {
+		return nil, err
+	}
+	return rh.Digest(), nil
+}
+
+// SHA256 returns the SHA256 digest of the content at the URL, preferring the
+// checksum stored by S3 over downloading the object.
+func SHA256(ctx context.Context, rawUrl string) (digest []byte, err error) {
+	defer wrapError("hash", rawUrl, &err)
+	logger := logging.Get(ctx)
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "s3" {
+		s3c, err := getS3(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		bucket, key := bucketKeyFromUrl(u)
+		logger, ctx = logging.WithAttrs(ctx, "bucket", bucket, "key", key)
+
+		logger.Debug("head object")
+		o, err := s3c.HeadObject(ctx, &s3.HeadObjectInput {
+			Bucket: aws.String(bucket),
+			Key: aws.String(key),
+			ChecksumMode: types.ChecksumModeEnabled,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// composite checksums of multipart uploads are suffixed with -N
+		if c := aws.ToString(o.ChecksumSHA256); c != "" && !strings.Contains(c, "-") {
+			logger.Debug("using stored checksum", "SHA256", c)
+			return base64.StdEncoding.DecodeString(c)
+		}
+	}
+
+	r, err := Open(ctx, rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return hashSHA256(r)
+}
+
+func Remove(ctx context.Context, rawUrl string) (err error) {
+	defer wrapError("remove", rawUrl, &err)
+	logger := logging.Get(ctx)
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "s3":
+		s3c, err := getS3(ctx)
+		if err != nil {
+			return err
+		}
+
+		bucket, key := bucketKeyFromUrl(u)
+		logger, ctx = logging.WithAttrs(ctx, "bucket", bucket, "key", key)
+
+		logger.Debug("delete object")
+		_, err = s3c.DeleteObject(ctx, &s3.DeleteObjectInput {
+			Bucket: aws.String(bucket),
+			Key: aws.String(key),
+		})
+		return err
+	case "", "file":
+		path := filepath.Join(u.Host, u.Path)
+
+		logger, _ = logging.WithAttrs(ctx, "path", path)
+
+		logger.Debug("remove")
+		return os.Remove(path)
+	default:
+		return ErrUnsupportedScheme
+	}
+}
+
+func Stat(ctx context.Context, rawUrl string) (e Entry, err error) {
+	defer wrapError("stat", rawUrl, &err)
+	logger := logging.Get(ctx)
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	switch u.Scheme {
+	case "s3":
+		s3c, err := getS3(ctx)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		bucket, key := bucketKeyFromUrl(u)
+		logger, ctx = logging.WithAttrs(ctx, "bucket", bucket, "key", key)
+
+		logger.Debug("head object")
+		o, err := s3c.HeadObject(ctx, &s3.HeadObjectInput {
+			Bucket: aws.String(bucket),
+			Key: aws.String(key),
+		})
+		if err != nil {
+			return Entry{}, err
+		}
+
+		return Entry { Name: path.Base(key), Size: aws.ToInt64(o.ContentLength) }, nil
+	case "", "file":
+		p := filepath.Join(u.Host, u.Path)
+
+		logger, _ = logging.WithAttrs(ctx, "path", p)
+
+		logger.Debug("stat")
+		i, err := os.Stat(p)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		return Entry { Name: i.Name(), Size: i.Size() }, nil
+	default:
+		return Entry{}, ErrUnsupportedScheme
+	}
+}
+
+// Rename moves src to dst, which must use the same scheme. Parent
+// directories of file destinations are created, and S3 objects are moved by
+// copying and then deleting the source.
+func Rename(ctx context.Context, src, dst string) (err error) {
+	defer wrapError("rename", src, &err)
+	logger := logging.Get(ctx)
+
+	su, err := url.Parse(src)
+	if err != nil {
+		return err
+	}
+
+	du, err := url.Parse(dst)
+	if err != nil {
+		return err
+	}
+
+	if su.Scheme != du.Scheme && !(su.Scheme == "" && du.Scheme == "file" || su.Scheme == "file" && du.Scheme == "") {
+		return fmt.Errorf("unable to rename across URL schemes: %s -> %s", su.Scheme, du.Scheme)
+	}
+
+	switch su.Scheme {
+	case "s3":
+		s3c, err := getS3(ctx)
+		if err != nil {
+			return err
+		}
+
+		srcBucket, srcKey := bucketKeyFromUrl(su)
+		dstBucket, dstKey := bucketKeyFromUrl(du)
+		logger, ctx = logging.WithAttrs(ctx, "src", src, "dst", dst)
+
+		logger.Debug("copy object")
+		_, err = s3c.CopyObject(ctx, &s3.CopyObjectInput {
+			Bucket: aws.String(dstBucket),
+			Key: aws.String(dstKey),
+			CopySource: aws.String(url.PathEscape(srcBucket) + "/" + (&url.URL { Path: srcKey }).EscapedPath()),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		})
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("delete object")
+		_, err = s3c.DeleteObject(ctx, &s3.DeleteObjectInput {
+			Bucket: aws.String(srcBucket),
+			Key: aws.String(srcKey),
+		})
+		return err
+	case "", "file":
+		sp := filepath.Join(su.Host, su.Path)
+		dp := filepath.Join(du.Host, du.Path)
+
+		logger, _ = logging.WithAttrs(ctx, "src", sp, "dst", dp)
+
+		if err := os.MkdirAll(filepath.Dir(dp), 0755); err != nil {
+			return err
+		}
+
+		logger.Debug("rename")
+		return os.Rename(sp, dp)
+	default:
+		return ErrUnsupportedScheme
 	}
 }
@@ -3,7 +3,13 @@ package osext
 import (
 	"testing"
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 
 	logging "rootmos.io/go-utils/logging/testing"
 )
@@ -19,3 +25,112 @@ func TestTarballNotExist(t *testing.T) {
 	}
 }
 
+func TestStdio(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	tmp := t.TempDir()
+
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	}()
+
+	in := filepath.Join(tmp, "in")
+	if err := os.WriteFile(in, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdin = f
+
+	g, err := os.Create(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	os.Stdout = g
+
+	r, err := Open(ctx, "-")
+	if err != nil {
+		t.Fatalf("unable to open stdin: %v", err)
+	}
+	defer r.Close()
+
+	if err := Create(ctx, "-", r); err != nil {
+		t.Fatalf("unable to write to stdout: %v", err)
+	}
+
+	bs, err := os.ReadFile(g.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "hello" {
+		t.Errorf("unexpected output: %q", bs)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	tmp := t.TempDir()
+
+	for _, n := range []string { "b/c", "a" } {
+		p := filepath.Join(tmp, n)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(n), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	es, err := List(ctx, tmp)
+	if err != nil {
+		t.Fatalf("unable to list: %v", err)
+	}
+
+	expected := []Entry { { Name: "a", Size: 1 }, { Name: "b/c", Size: 3 } }
+	if !reflect.DeepEqual(es, expected) {
+		t.Errorf("unexpected entries: %v != %v", es, expected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	_, err := Open(ctx, srv.URL + "/forbidden")
+	if !IsPermission(err) || IsNotExist(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = Open(ctx, srv.URL + "/noent")
+	if !IsNotExist(err) || IsPermission(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var ue *URLError
+	if !errors.As(err, &ue) || ue.Op != "open" || ue.URL != srv.URL + "/noent" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = Open(ctx, "ftp://example.com/foo")
+	if !errors.Is(err, ErrUnsupportedScheme) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = Create(ctx, t.TempDir(), strings.NewReader(""))
+	if IsNotExist(err) || IsPermission(err) || err == nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package osext_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"rootmos.io/go-utils/osext"
+	osexttesting "rootmos.io/go-utils/osext/testing"
+	logging "rootmos.io/go-utils/logging/testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// a fixed seed keeps the generated objects, and so any failure, reproducible
+var prng = rand.New(rand.NewSource(0x733374657374))
+
+func freshBytes(n int) []byte {
+	bs := make([]byte, n)
+	_, _ = prng.Read(bs)
+	return bs
+}
+
+func readAll(t *testing.T, ctx context.Context, url string) []byte {
+	r, err := osext.Open(ctx, url)
+	if err != nil {
+		t.Fatalf("unable to open %s: %v", url, err)
+	}
+	defer r.Close()
+
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", url, err)
+	}
+	return bs
+}
+
+func TestS3Roundtrip(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	data := freshBytes(prng.Intn(4096))
+	if err := osext.Create(ctx, "s3://bucket/foo/bar.json", bytes.NewReader(data)); err != nil {
+		t.Fatalf("unable to create object: %v", err)
+	}
+
+	if bs := readAll(t, ctx, "s3://bucket/foo/bar.json"); !bytes.Equal(bs, data) {
+		t.Errorf("unexpected object content")
+	}
+
+	o, ok := srv.Object("bucket", "foo/bar.json")
+	if !ok {
+		t.Fatalf("object not stored")
+	}
+
+	sum := sha256.Sum256(data)
+	if o.ChecksumSHA256 != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("unexpected checksum: %s", o.ChecksumSHA256)
+	}
+
+	if o.ContentType != "application/json" {
+		t.Errorf("unexpected content type: %s", o.ContentType)
+	}
+}
+
+func TestS3CreateStream(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	for _, c := range []struct {
+		size int
+		parts int
+	} {
+		{ size: prng.Intn(4096), parts: 0 },
+		{ size: 2*int(manager.DefaultUploadPartSize) + prng.Intn(4096), parts: 3 },
+	} {
+		data := freshBytes(c.size)
+		// hide Seek, as of stdin when piped
+		r := struct { io.Reader } { bytes.NewReader(data) }
+		if err := osext.Create(ctx, "s3://bucket/stream.json", r); err != nil {
+			t.Fatalf("unable to create object: %v", err)
+		}
+
+		o, ok := srv.Object("bucket", "stream.json")
+		if !ok {
+			t.Fatalf("object not stored")
+		}
+		if !bytes.Equal(o.Data, data) {
+			t.Errorf("unexpected object content of %d bytes", c.size)
+		}
+		if o.ContentType != "application/json" {
+			t.Errorf("unexpected content type: %s", o.ContentType)
+		}
+		if c.parts > 0 && !strings.HasSuffix(o.ChecksumSHA256, fmt.Sprintf("-%d", c.parts)) {
+			t.Errorf("unexpected checksum of %d bytes: %s", c.size, o.ChecksumSHA256)
+		}
+
+		sum := sha256.Sum256(data)
+		if d, err := osext.SHA256(ctx, "s3://bucket/stream.json"); err != nil || !bytes.Equal(d, sum[:]) {
+			t.Errorf("unexpected SHA256 of %d bytes: %x %v", c.size, d, err)
+		}
+	}
+}
+
+func TestS3NotExist(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, _ = osexttesting.SetupS3(ctx, t, "bucket")
+
+	_, err := osext.Open(ctx, "s3://bucket/noent")
+	if !osext.IsNotExist(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestS3CreateContentType(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	for _, c := range []struct {
+		key string
+		opts *osext.CreateOptions
+		expected string
+	} {
+		{ key: "explicit.json", opts: &osext.CreateOptions { ContentType: "text/x-foo" }, expected: "text/x-foo" },
+		{ key: "guessed.html", opts: &osext.CreateOptions {}, expected: "text/html; charset=utf-8" },
+		{ key: "default.json", expected: "application/json" },
+		// left to S3, defaulting to binary
+		{ key: "unguessed.html", opts: &osext.CreateOptions { NoGuessContentType: true }, expected: "application/octet-stream" },
+	} {
+		if err := osext.CreateWithOptions(ctx, "s3://bucket/" + c.key, strings.NewReader("hello"), c.opts); err != nil {
+			t.Fatalf("unable to create object: %v", err)
+		}
+
+		o, ok := srv.Object("bucket", c.key)
+		if !ok {
+			t.Fatalf("object not stored: %s", c.key)
+		}
+		if o.ContentType != c.expected {
+			t.Errorf("unexpected content type of %s: %q", c.key, o.ContentType)
+		}
+	}
+}
+
+func TestS3CreateMetadata(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	opts := osext.CreateOptions {
+		CacheControl: "no-cache",
+		Metadata: map[string]string { "foo": "bar", "baz": "qux" },
+	}
+	if err := osext.CreateWithOptions(ctx, "s3://bucket/key", strings.NewReader("hello"), &opts); err != nil {
+		t.Fatalf("unable to create object: %v", err)
+	}
+
+	o, ok := srv.Object("bucket", "key")
+	if !ok {
+		t.Fatalf("object not stored")
+	}
+
+	if o.CacheControl != opts.CacheControl {
+		t.Errorf("unexpected cache control: %s", o.CacheControl)
+	}
+	if len(o.Metadata) != 2 || o.Metadata["foo"] != "bar" || o.Metadata["baz"] != "qux" {
+		t.Errorf("unexpected metadata: %v", o.Metadata)
+	}
+}
+
+func TestS3CreateOptions(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	opts := osext.CreateOptions {
+		StorageClass: "STANDARD_IA",
+		SSEKMSKeyId: "alias/foo",
+		Tags: map[string]string { "a": "b c" },
+	}
+	if err := osext.CreateWithOptions(ctx, "s3://bucket/key", strings.NewReader("hello"), &opts); err != nil {
+		t.Fatalf("unable to create object: %v", err)
+	}
+
+	o, ok := srv.Object("bucket", "key")
+	if !ok {
+		t.Fatalf("object not stored")
+	}
+
+	if o.StorageClass != opts.StorageClass {
+		t.Errorf("unexpected storage class: %s", o.StorageClass)
+	}
+	if o.ServerSideEncryption != "aws:kms" || o.SSEKMSKeyId != opts.SSEKMSKeyId {
+		t.Errorf("unexpected SSE settings: %s %s", o.ServerSideEncryption, o.SSEKMSKeyId)
+	}
+	if o.Tagging != "a=b+c" {
+		t.Errorf("unexpected tagging: %s", o.Tagging)
+	}
+}
+
+func TestS3ChecksumMismatch(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	_, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	sum := sha256.Sum256([]byte("something else"))
+	_, err := srv.Client().PutObject(ctx, &s3.PutObjectInput {
+		Bucket: aws.String("bucket"),
+		Key: aws.String("key"),
+		Body: strings.NewReader("hello"),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	})
+
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) || apiError.ErrorCode() != "BadDigest" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, ok := srv.Object("bucket", "key"); ok {
+		t.Errorf("object stored despite checksum mismatch")
+	}
+}
+
+func TestS3Multipart(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	_, srv := osexttesting.SetupS3(ctx, t, "bucket")
+	s3c := srv.Client()
+
+	cmu, err := s3c.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput {
+		Bucket: aws.String("bucket"),
+		Key: aws.String("key"),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		t.Fatalf("unable to create multipart upload: %v", err)
+	}
+
+	var data []byte
+	var parts []types.CompletedPart
+	for i := int32(1); i <= 3; i++ {
+		p := freshBytes(1024 + prng.Intn(1024))
+		data = append(data, p...)
+
+		sum := sha256.Sum256(p)
+		up, err := s3c.UploadPart(ctx, &s3.UploadPartInput {
+			Bucket: aws.String("bucket"),
+			Key: aws.String("key"),
+			UploadId: cmu.UploadId,
+			PartNumber: aws.Int32(i),
+			Body: bytes.NewReader(p),
+			ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		})
+		if err != nil {
+			t.Fatalf("unable to upload part %d: %v", i, err)
+		}
+
+		parts = append(parts, types.CompletedPart {
+			PartNumber: aws.Int32(i),
+			ETag: up.ETag,
+			ChecksumSHA256: up.ChecksumSHA256,
+		})
+	}
+
+	cmp, err := s3c.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput {
+		Bucket: aws.String("bucket"),
+		Key: aws.String("key"),
+		UploadId: cmu.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload { Parts: parts },
+	})
+	if err != nil {
+		t.Fatalf("unable to complete multipart upload: %v", err)
+	}
+
+	if !strings.HasSuffix(aws.ToString(cmp.ETag), `-3"`) {
+		t.Errorf("unexpected ETag: %s", aws.ToString(cmp.ETag))
+	}
+
+	o, ok := srv.Object("bucket", "key")
+	if !ok {
+		t.Fatalf("object not stored")
+	}
+	if !bytes.Equal(o.Data, data) {
+		t.Errorf("unexpected object content")
+	}
+}
+
+func TestS3List(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	_, srv := osexttesting.SetupS3(ctx, t, "bucket")
+	s3c := srv.Client()
+
+	keys := []string { "a/1", "a/2", "a/3", "b/1" }
+	for _, k := range keys {
+		_, err := s3c.PutObject(ctx, &s3.PutObjectInput {
+			Bucket: aws.String("bucket"),
+			Key: aws.String(k),
+			Body: strings.NewReader(k),
+		})
+		if err != nil {
+			t.Fatalf("unable to put object: %v", err)
+		}
+	}
+
+	var listed []string
+	p := s3.NewListObjectsV2Paginator(s3c, &s3.ListObjectsV2Input {
+		Bucket: aws.String("bucket"),
+		Prefix: aws.String("a/"),
+		MaxKeys: aws.Int32(2),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			t.Fatalf("unable to list objects: %v", err)
+		}
+		for _, o := range page.Contents {
+			listed = append(listed, aws.ToString(o.Key))
+		}
+	}
+
+	if strings.Join(listed, ",") != "a/1,a/2,a/3" {
+		t.Errorf("unexpected listing: %v", listed)
+	}
+
+	_, err := s3c.DeleteObject(ctx, &s3.DeleteObjectInput {
+		Bucket: aws.String("bucket"),
+		Key: aws.String("a/2"),
+	})
+	if err != nil {
+		t.Fatalf("unable to delete object: %v", err)
+	}
+
+	if ks := strings.Join(srv.Keys("bucket"), ","); ks != "a/1,a/3,b/1" {
+		t.Errorf("unexpected keys after delete: %s", ks)
+	}
+}
+
+func TestS3ListAndSHA256(t *testing.T) {
+	ctx := logging.SetupTestLogger(context.TODO(), t)
+	ctx, srv := osexttesting.SetupS3(ctx, t, "bucket")
+
+	data := map[string][]byte {
+		"a": freshBytes(prng.Intn(4096)),
+		"b/c": freshBytes(prng.Intn(4096)),
+	}
+	for n, bs := range data {
+		if err := osext.Create(ctx, osext.Join("s3://bucket/prefix", n), bytes.NewReader(bs)); err != nil {
+			t.Fatalf("unable to create object: %v", err)
+		}
+	}
+
+	// an object without a stored checksum forces hashing the content
+	_, err := srv.Client().PutObject(ctx, &s3.PutObjectInput {
+		Bucket: aws.String("bucket"),
+		Key: aws.String("prefix/d"),
+		Body: strings.NewReader("d"),
+	})
+	if err != nil {
+		t.Fatalf("unable to put object: %v", err)
+	}
+	data["d"] = []byte("d")
+
+	es, err := osext.List(ctx, "s3://bucket/prefix")
+	if err != nil {
+		t.Fatalf("unable to list: %v", err)
+	}
+
+	if len(es) != len(data) {
+		t.Fatalf("unexpected entries: %v", es)
+	}
+	for _, e := range es {
+		bs, ok := data[e.Name]
+		if !ok || e.Size != int64(len(bs)) {
+			t.Errorf("unexpected entry: %v", e)
+			continue
+		}
+
+		h, err := osext.SHA256(ctx, osext.Join("s3://bucket/prefix", e.Name))
+		if err != nil {
+			t.Fatalf("unable to get SHA256: %v", err)
+		}
+		if sum := sha256.Sum256(bs); !bytes.Equal(h, sum[:]) {
+			t.Errorf("unexpected SHA256 of %s", e.Name)
+		}
+	}
+
+	if err := osext.Remove(ctx, "s3://bucket/prefix/a"); err != nil {
+		t.Fatalf("unable to remove: %v", err)
+	}
+	if _, ok := srv.Object("bucket", "prefix/a"); ok {
+		t.Errorf("object not removed")
+	}
+}
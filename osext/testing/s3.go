@@ -0,0 +1,801 @@
+package testing
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type Object struct {
+	Data []byte
+	ETag string
+	ChecksumSHA256 string
+	ContentType string
+	CacheControl string
+	Metadata map[string]string
+	StorageClass string
+	ServerSideEncryption string
+	SSEKMSKeyId string
+	Tagging string
+	LastModified time.Time
+}
+
+type part struct {
+	data []byte
+	etag string
+	checksumSHA256 []byte
+}
+
+type upload struct {
+	bucket string
+	key string
+	object Object
+	parts map[int]*part
+}
+
+type S3Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	buckets map[string]map[string]*Object
+	uploads map[string]*upload
+}
+
+func NewS3Server() *S3Server {
+	s := &S3Server {
+		buckets: make(map[string]map[string]*Object),
+		uploads: make(map[string]*upload),
+	}
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+func (s *S3Server) CreateBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucket]; !ok {
+		s.buckets[bucket] = make(map[string]*Object)
+	}
+}
+
+func (s *S3Server) Object(bucket, key string) (*Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil, false
+	}
+	o, ok := b[key]
+	return o, ok
+}
+
+func (s *S3Server) Keys(bucket string) (keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code string
+	Message string
+	Resource string `xml:",omitempty"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	writeXML(w, status, &s3Error { Code: code, Message: msg, Resource: r.URL.Path })
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	bs, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	_, _ = w.Write(bs)
+}
+
+func splitPath(p string) (bucket, key string) {
+	p = strings.TrimPrefix(p, "/")
+	bucket, key, _ = strings.Cut(p, "/")
+	return
+}
+
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key := splitPath(r.URL.EscapedPath())
+	var err error
+	if key, err = url.PathUnescape(key); err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidURI", err.Error())
+		return
+	}
+	q := r.URL.Query()
+
+	if bucket == "" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "service level operations are not supported")
+		return
+	}
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPut:
+			s.CreateBucket(bucket)
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && q.Get("list-type") == "2":
+			s.listObjectsV2(w, r, bucket)
+		default:
+			writeError(w, r, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("unsupported bucket operation: %s %s", r.Method, r.URL))
+		}
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		s.createMultipartUpload(w, r, bucket, key)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		s.uploadPart(w, r, q.Get("uploadId"), q.Get("partNumber"))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		s.completeMultipartUpload(w, r, bucket, key, q.Get("uploadId"))
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		s.abortMultipartUpload(w, r, q.Get("uploadId"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, bucket, key)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		s.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.deleteObject(w, r, bucket, key)
+	default:
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("unsupported object operation: %s %s", r.Method, r.URL))
+	}
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	if strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") ||
+		strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return readChunked(r)
+	}
+	return io.ReadAll(r.Body)
+}
+
+// readChunked decodes the aws-chunked content encoding, moving any trailing
+// headers (e.g. x-amz-checksum-sha256) into the request's headers.
+func readChunked(r *http.Request) ([]byte, error) {
+	var buf bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		l, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(l), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size: %q", l)
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := io.CopyN(&buf, br, n); err != nil {
+			return nil, err
+		}
+		if _, err := br.ReadString('\n'); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		l, err := br.ReadString('\n')
+		l = strings.TrimSpace(l)
+		if l != "" {
+			k, v, _ := strings.Cut(l, ":")
+			r.Header.Set(k, strings.TrimSpace(v))
+		}
+		if err == io.EOF || (err == nil && l == "") {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func verifyChecksums(r *http.Request, data []byte) (sha []byte, code, msg string) {
+	if c := r.Header.Get("Content-Md5"); c != "" {
+		sum := md5.Sum(data)
+		if c != base64.StdEncoding.EncodeToString(sum[:]) {
+			return nil, "BadDigest", "The Content-MD5 you specified did not match what we received."
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	if c := r.Header.Get("X-Amz-Checksum-Sha256"); c != "" {
+		if c != base64.StdEncoding.EncodeToString(sum[:]) {
+			return nil, "BadDigest", "The SHA256 you specified did not match the calculated checksum."
+		}
+		return sum[:], "", ""
+	}
+
+	if c := r.Header.Get("X-Amz-Content-Sha256"); c != "" && !strings.HasPrefix(c, "STREAMING-") && c != "UNSIGNED-PAYLOAD" {
+		if c != hex.EncodeToString(sum[:]) {
+			return nil, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed."
+		}
+	}
+
+	return nil, "", ""
+}
+
+func objectFromHeaders(h http.Header) Object {
+	o := Object {
+		ContentType: h.Get("Content-Type"),
+		CacheControl: h.Get("Cache-Control"),
+		StorageClass: h.Get("X-Amz-Storage-Class"),
+		ServerSideEncryption: h.Get("X-Amz-Server-Side-Encryption"),
+		SSEKMSKeyId: h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"),
+		Tagging: h.Get("X-Amz-Tagging"),
+	}
+	for k, vs := range h {
+		if m, ok := strings.CutPrefix(strings.ToLower(k), "x-amz-meta-"); ok {
+			if o.Metadata == nil {
+				o.Metadata = make(map[string]string)
+			}
+			o.Metadata[m] = vs[0]
+		}
+	}
+	return o
+}
+
+func (s *S3Server) bucket(w http.ResponseWriter, r *http.Request, bucket string) (map[string]*Object, bool) {
+	b, ok := s.buckets[bucket]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	return b, ok
+}
+
+func (s *S3Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	sha, code, msg := verifyChecksums(r, data)
+	if code != "" {
+		writeError(w, r, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	o := objectFromHeaders(r.Header)
+	o.Data = data
+	md5sum := md5.Sum(data)
+	o.ETag = fmt.Sprintf("%q", hex.EncodeToString(md5sum[:]))
+	if sha != nil {
+		o.ChecksumSHA256 = base64.StdEncoding.EncodeToString(sha)
+	}
+	o.LastModified = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bucket(w, r, bucket)
+	if !ok {
+		return
+	}
+	b[key] = &o
+
+	w.Header().Set("ETag", o.ETag)
+	if o.ChecksumSHA256 != "" {
+		w.Header().Set("X-Amz-Checksum-Sha256", o.ChecksumSHA256)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type copyObjectResult struct {
+	XMLName xml.Name `xml:"CopyObjectResult"`
+	Xmlns string `xml:"xmlns,attr"`
+	ETag string
+	LastModified string
+	ChecksumSHA256 string `xml:",omitempty"`
+}
+
+func (s *S3Server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	src, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+	src, _, _ = strings.Cut(src, "?")
+	srcBucket, srcKey := splitPath(src)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sb, ok := s.bucket(w, r, srcBucket)
+	if !ok {
+		return
+	}
+	so, ok := sb[srcKey]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	db, ok := s.bucket(w, r, bucket)
+	if !ok {
+		return
+	}
+
+	o := *so
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		d := o.Data
+		o = objectFromHeaders(r.Header)
+		o.Data = d
+		o.ETag = so.ETag
+		o.ChecksumSHA256 = so.ChecksumSHA256
+	}
+	if strings.EqualFold(r.Header.Get("X-Amz-Checksum-Algorithm"), "SHA256") && (o.ChecksumSHA256 == "" || strings.Contains(o.ChecksumSHA256, "-")) {
+		sum := sha256.Sum256(o.Data)
+		o.ChecksumSHA256 = base64.StdEncoding.EncodeToString(sum[:])
+	}
+	o.LastModified = time.Now().UTC()
+	db[key] = &o
+
+	writeXML(w, http.StatusOK, &copyObjectResult {
+		Xmlns: s3Namespace,
+		ETag: o.ETag,
+		LastModified: o.LastModified.Format(time.RFC3339),
+		ChecksumSHA256: o.ChecksumSHA256,
+	})
+}
+
+func parseRange(h string, size int) (start, end int, ok bool) {
+	spec, found := strings.CutPrefix(h, "bytes=")
+	if !found {
+		return 0, 0, false
+	}
+	a, b, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	var err error
+	switch {
+	case a == "":
+		n, err := strconv.Atoi(b)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	case b == "":
+		end = size - 1
+	default:
+		if end, err = strconv.Atoi(b); err != nil {
+			return 0, 0, false
+		}
+	}
+	if start, err = strconv.Atoi(a); err != nil || start >= size || start > end {
+		return 0, 0, false
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, true
+}
+
+func (s *S3Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.mu.Lock()
+	b, ok := s.bucket(w, r, bucket)
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	o, ok := b[key]
+	s.mu.Unlock()
+	if !ok {
+		if r.Method == http.MethodHead {
+			writeError(w, r, http.StatusNotFound, "NotFound", "Not Found")
+		} else {
+			writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		}
+		return
+	}
+
+	h := w.Header()
+	h.Set("ETag", o.ETag)
+	h.Set("Last-Modified", o.LastModified.Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+	if o.ContentType != "" {
+		h.Set("Content-Type", o.ContentType)
+	} else {
+		h.Set("Content-Type", "binary/octet-stream")
+	}
+	if o.CacheControl != "" {
+		h.Set("Cache-Control", o.CacheControl)
+	}
+	if o.StorageClass != "" {
+		h.Set("X-Amz-Storage-Class", o.StorageClass)
+	}
+	if o.ServerSideEncryption != "" {
+		h.Set("X-Amz-Server-Side-Encryption", o.ServerSideEncryption)
+	}
+	if o.SSEKMSKeyId != "" {
+		h.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", o.SSEKMSKeyId)
+	}
+	for k, v := range o.Metadata {
+		h.Set("X-Amz-Meta-" + k, v)
+	}
+
+	data := o.Data
+	status := http.StatusOK
+	if rh := r.Header.Get("Range"); rh != "" {
+		start, end, ok := parseRange(rh, len(data))
+		if !ok {
+			writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+			return
+		}
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start:end+1]
+		status = http.StatusPartialContent
+	} else if o.ChecksumSHA256 != "" && strings.EqualFold(r.Header.Get("X-Amz-Checksum-Mode"), "ENABLED") {
+		h.Set("X-Amz-Checksum-Sha256", o.ChecksumSHA256)
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+func (s *S3Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bucket(w, r, bucket)
+	if !ok {
+		return
+	}
+	delete(b, key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type listContents struct {
+	Key string
+	LastModified string
+	ETag string
+	Size int
+	StorageClass string
+	ChecksumAlgorithm string `xml:",omitempty"`
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+type listBucketResult struct {
+	XMLName xml.Name `xml:"ListBucketResult"`
+	Xmlns string `xml:"xmlns,attr"`
+	Name string
+	Prefix string
+	Delimiter string `xml:",omitempty"`
+	MaxKeys int
+	KeyCount int
+	IsTruncated bool
+	ContinuationToken string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	StartAfter string `xml:",omitempty"`
+	Contents []listContents
+	CommonPrefixes []listPrefix
+}
+
+func (s *S3Server) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	res := listBucketResult {
+		Xmlns: s3Namespace,
+		Name: bucket,
+		Prefix: q.Get("prefix"),
+		Delimiter: q.Get("delimiter"),
+		MaxKeys: 1000,
+		ContinuationToken: q.Get("continuation-token"),
+		StartAfter: q.Get("start-after"),
+	}
+	if mk := q.Get("max-keys"); mk != "" {
+		n, err := strconv.Atoi(mk)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid max-keys")
+			return
+		}
+		res.MaxKeys = n
+	}
+
+	after := res.StartAfter
+	if res.ContinuationToken != "" {
+		bs, err := base64.StdEncoding.DecodeString(res.ContinuationToken)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+			return
+		}
+		after = string(bs)
+	}
+
+	s.mu.Lock()
+	b, ok := s.bucket(w, r, bucket)
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	var keys []string
+	for k := range b {
+		if strings.HasPrefix(k, res.Prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if res.KeyCount >= res.MaxKeys {
+			res.IsTruncated = true
+			break
+		}
+
+		if res.Delimiter != "" {
+			if i := strings.Index(k[len(res.Prefix):], res.Delimiter); i >= 0 {
+				p := k[:len(res.Prefix)+i+len(res.Delimiter)]
+				if !seen[p] {
+					seen[p] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, listPrefix { Prefix: p })
+					res.KeyCount += 1
+				}
+				after = k
+				continue
+			}
+		}
+
+		o := b[k]
+		c := listContents {
+			Key: k,
+			LastModified: o.LastModified.Format(time.RFC3339),
+			ETag: o.ETag,
+			Size: len(o.Data),
+			StorageClass: o.StorageClass,
+		}
+		if c.StorageClass == "" {
+			c.StorageClass = "STANDARD"
+		}
+		if o.ChecksumSHA256 != "" {
+			c.ChecksumAlgorithm = "SHA256"
+		}
+		res.Contents = append(res.Contents, c)
+		res.KeyCount += 1
+		after = k
+	}
+	s.mu.Unlock()
+
+	if res.IsTruncated {
+		res.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(after))
+	}
+
+	writeXML(w, http.StatusOK, &res)
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns string `xml:"xmlns,attr"`
+	Bucket string
+	Key string
+	UploadId string
+}
+
+func (s *S3Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	var bs [16]byte
+	if _, err := rand.Read(bs[:]); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	id := hex.EncodeToString(bs[:])
+
+	s.mu.Lock()
+	if _, ok := s.bucket(w, r, bucket); !ok {
+		s.mu.Unlock()
+		return
+	}
+	s.uploads[id] = &upload {
+		bucket: bucket,
+		key: key,
+		object: objectFromHeaders(r.Header),
+		parts: make(map[int]*part),
+	}
+	s.mu.Unlock()
+
+	writeXML(w, http.StatusOK, &initiateMultipartUploadResult {
+		Xmlns: s3Namespace,
+		Bucket: bucket,
+		Key: key,
+		UploadId: id,
+	})
+}
+
+func (s *S3Server) uploadPart(w http.ResponseWriter, r *http.Request, id, partNumber string) {
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > 10000 {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
+		return
+	}
+
+	data, err := readBody(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	sha, code, msg := verifyChecksums(r, data)
+	if code != "" {
+		writeError(w, r, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	md5sum := md5.Sum(data)
+	p := &part {
+		data: data,
+		etag: fmt.Sprintf("%q", hex.EncodeToString(md5sum[:])),
+		checksumSHA256: sha,
+	}
+
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	if ok {
+		u.parts[n] = p
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+
+	w.Header().Set("ETag", p.etag)
+	if sha != nil {
+		w.Header().Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sha))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int
+		ETag string
+		ChecksumSHA256 string
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns string `xml:"xmlns,attr"`
+	Location string
+	Bucket string
+	Key string
+	ETag string
+	ChecksumSHA256 string `xml:",omitempty"`
+}
+
+func (s *S3Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, id string) {
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	if len(req.Parts) == 0 {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "no parts specified")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok || u.bucket != bucket || u.key != key {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+
+	var data bytes.Buffer
+	md5s := md5.New()
+	shas := sha256.New()
+	withSHA := true
+	prev := 0
+	for _, rp := range req.Parts {
+		if rp.PartNumber <= prev {
+			writeError(w, r, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.")
+			return
+		}
+		prev = rp.PartNumber
+
+		p, ok := u.parts[rp.PartNumber]
+		if !ok || strings.Trim(rp.ETag, `"`) != strings.Trim(p.etag, `"`) {
+			writeError(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+		if rp.ChecksumSHA256 != "" && (p.checksumSHA256 == nil || rp.ChecksumSHA256 != base64.StdEncoding.EncodeToString(p.checksumSHA256)) {
+			writeError(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+
+		data.Write(p.data)
+		etag, _ := hex.DecodeString(strings.Trim(p.etag, `"`))
+		md5s.Write(etag)
+		if p.checksumSHA256 == nil {
+			withSHA = false
+		} else {
+			shas.Write(p.checksumSHA256)
+		}
+	}
+
+	o := u.object
+	o.Data = data.Bytes()
+	o.ETag = fmt.Sprintf("\"%s-%d\"", hex.EncodeToString(md5s.Sum(nil)), len(req.Parts))
+	if withSHA {
+		o.ChecksumSHA256 = fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(shas.Sum(nil)), len(req.Parts))
+	}
+	o.LastModified = time.Now().UTC()
+
+	b, ok := s.bucket(w, r, bucket)
+	if !ok {
+		return
+	}
+	b[key] = &o
+	delete(s.uploads, id)
+
+	writeXML(w, http.StatusOK, &completeMultipartUploadResult {
+		Xmlns: s3Namespace,
+		Location: fmt.Sprintf("%s/%s/%s", s.URL, bucket, key),
+		Bucket: bucket,
+		Key: key,
+		ETag: o.ETag,
+		ChecksumSHA256: o.ChecksumSHA256,
+	})
+}
+
+func (s *S3Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	_, ok := s.uploads[id]
+	delete(s.uploads, id)
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"rootmos.io/go-utils/osext"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func (s *S3Server) Client() *s3.Client {
+	return s3.New(s3.Options {
+		BaseEndpoint: aws.String(s.URL),
+		UsePathStyle: true,
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials {
+				AccessKeyID: "AKIDFAKES3",
+				SecretAccessKey: "fake-s3-secret",
+				Source: "osext/testing",
+			}, nil
+		}),
+		HTTPClient: s.Server.Client(),
+	})
+}
+
+func SetupS3(ctx context.Context, t *testing.T, buckets ...string) (context.Context, *S3Server) {
+	s := NewS3Server()
+	t.Cleanup(s.Close)
+
+	for _, b := range buckets {
+		s.CreateBucket(b)
+	}
+
+	return osext.SetS3Client(ctx, s.Client()), s
+}